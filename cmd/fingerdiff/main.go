@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+
+// fingerdiff 比较两个TLS指纹的差异
+//
+// 用法:
+//
+//	fingerdiff [-sni example.com] [-color auto|always|never] <指纹A> <指纹B>
+//
+// 指纹可以是内置预设名称 (如 chrome_124)、JSON/YAML 指纹描述文件、pcap/pcapng 抓包文件，
+// 或以 "ja3:" 开头的JA3字符串。存在差异时退出码为1。
+// 默认仅在标准输出为终端且未设置 NO_COLOR 环境变量时输出彩色差异
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 执行比较并返回退出码: 0 一致，1 存在差异，2 参数或加载错误
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fingerdiff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	sni := flags.String("sni", "", "从抓包文件中提取ClientHello时按SNI过滤")
+	color := flags.String("color", "auto", "彩色输出: auto (仅终端)、always 或 never")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "用法: %s [-sni 主机名] [-color auto|always|never] <指纹A> <指纹B>\n", flags.Name())
+		fmt.Fprintf(stderr, "指纹可以是预设名称 (%s)、.json/.yaml 文件、.pcap/.pcapng 文件或 ja3:<JA3字符串>\n", strings.Join(fingerprint.ProfileNames(), ", "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	colored, err := useColor(*color, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	a, err := loadSpec(flags.Arg(0), *sni)
+	if err != nil {
+		fmt.Fprintf(stderr, "加载指纹 %s 失败: %v\n", flags.Arg(0), err)
+		return 2
+	}
+	b, err := loadSpec(flags.Arg(1), *sni)
+	if err != nil {
+		fmt.Fprintf(stderr, "加载指纹 %s 失败: %v\n", flags.Arg(1), err)
+		return 2
+	}
+
+	diffs, err := fingerprint.Diff(a, b)
+	if err != nil {
+		fmt.Fprintf(stderr, "比较指纹失败: %v\n", err)
+		return 2
+	}
+	if len(diffs) == 0 {
+		fmt.Fprintln(stdout, "两个指纹完全一致")
+		return 0
+	}
+
+	fmt.Fprintf(stdout, "A: %s\nB: %s\n\n", flags.Arg(0), flags.Arg(1))
+	for _, d := range diffs {
+		if colored {
+			fmt.Fprintf(stdout, "%s\n  \033[31m- %s\033[0m\n  \033[32m+ %s\033[0m\n", d.Field, d.A, d.B)
+		} else {
+			fmt.Fprintf(stdout, "%s\n  - %s\n  + %s\n", d.Field, d.A, d.B)
+		}
+	}
+	return 1
+}
+
+// useColor 根据 -color 参数决定是否输出ANSI颜色，auto 时仅在输出为终端且未设置 NO_COLOR 时启用
+func useColor(mode string, out io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+		f, ok := out.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		fi, err := f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("无效的 -color 参数 %q，可选值为 auto、always、never", mode)
+}
+
+// loadSpec 根据参数形式加载指纹
+func loadSpec(arg, sni string) (fingerprint.SpecFactory, error) {
+	if ja3, ok := strings.CutPrefix(arg, "ja3:"); ok {
+		return fingerprint.FromJA3(ja3)
+	}
+
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".json", ".yaml", ".yml":
+		return fingerprint.LoadSpecFile(arg)
+	case ".pcap", ".pcapng", ".cap":
+		return fingerprint.FromPcap(arg, sni)
+	}
+	return fingerprint.Profile(arg)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{name: "一致", args: []string{"chrome_124", "chrome_124"}, wantCode: 0, wantStdout: "两个指纹完全一致"},
+		{name: "存在差异", args: []string{"chrome_124", "firefox_120"}, wantCode: 1, wantStdout: "A: chrome_124\nB: firefox_120"},
+		{name: "缺少参数", args: []string{"chrome_124"}, wantCode: 2, wantStderr: "用法:"},
+		{name: "未知指纹", args: []string{"chrome_124", "no_such_profile"}, wantCode: 2, wantStderr: "加载指纹 no_such_profile 失败"},
+		{name: "无效的JA3", args: []string{"chrome_124", "ja3:invalid"}, wantCode: 2, wantStderr: "加载指纹 ja3:invalid 失败"},
+		{name: "无效的颜色参数", args: []string{"-color", "rainbow", "chrome_124", "firefox_120"}, wantCode: 2, wantStderr: "无效的 -color 参数"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("run() = %d, want %d\nstderr: %s", code, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("stdout = %q, 缺少 %q", stdout.String(), tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr = %q, 缺少 %q", stderr.String(), tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestRunColor(t *testing.T) {
+	// 清除 NO_COLOR，测试结束后由 t.Setenv 恢复
+	t.Setenv("NO_COLOR", "")
+	os.Unsetenv("NO_COLOR")
+	tests := []struct {
+		args      []string
+		wantColor bool
+	}{
+		// 输出不是终端时 auto 不输出颜色
+		{args: []string{"chrome_124", "firefox_120"}},
+		{args: []string{"-color", "never", "chrome_124", "firefox_120"}},
+		{args: []string{"-color", "always", "chrome_124", "firefox_120"}, wantColor: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tt.args, &stdout, &stderr); code != 1 {
+				t.Fatalf("run() = %d, want 1\nstderr: %s", code, stderr.String())
+			}
+			if got := strings.Contains(stdout.String(), "\033["); got != tt.wantColor {
+				t.Errorf("输出包含ANSI颜色 = %v, want %v", got, tt.wantColor)
+			}
+		})
+	}
+}
@@ -82,12 +82,15 @@ func handleHttpsRequest(rawRequest *http.Request, upstreamProxy *url.URL) (*http
 		Host:   rawRequest.Host,
 	}
 
-	dialer := tls.NewTLSDialer(
+	dialer, err := tls.NewTLSDialerE(
 		tls.WithSpecFactory(fingerprint.GetDefaultClientHelloSpec),
 		tls.WithUpstreamProxy(upstreamProxy),
 		tls.WithProxyTimeout(30*time.Second),
 		tls.WithTimeout(30*time.Second),
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	fhTr := transport.NewFingerHttpsTransport(dialer)
 
@@ -162,7 +165,7 @@ func main() {
 	// 无法直接重新赋值 ps.Tr，来实现额外的功能;
 	// 因为需要自定义 tls 指纹除了需要定义各项 tls 能力插件一致以外，同样需要保证提供插件的顺序，而 http.Transport 类型中，依赖的 crypto/tls 库未提供保证顺序的实现
 	// 所以使用了第三方的 utls 实现，但是也带来了在 http2 下无法直接兼容 http.Transport 的问题。
-	// 因为需要直接替换 http.Transport 中的 DialTLSContext 相关的实现，这部分会返回一个 net.Conn 接口，实际类型为 *utls.UConn, 而不是 *tls.Conn，所以 http.Transport 无法获取到正确的 tls.ConnectionState
+	// 因为需要直接替换 http.Transport 中的 DialTLSContext 相关的实现，这部分会返回一个 net.Conn 接口，实际类型为基于 *utls.UConn 的 tls.IFingerConn, 而不是 *tls.Conn，所以 http.Transport 无法获取到正确的 tls.ConnectionState
 	// 需要使用 http2.Transport 来实现 http2 的支持
 
 	ps.OnRequest().DoFunc(
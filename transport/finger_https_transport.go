@@ -19,51 +19,150 @@ package transport
 
 import (
 	"context"
-	ctls "crypto/tls"
+	"errors"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/aberstone/fingertls/transport/tls"
-	utls "github.com/refraction-networking/utls"
 	"golang.org/x/net/http2"
 )
 
+// idleConnTimeout 空闲连接的保留时间
+const idleConnTimeout = 90 * time.Second
+
+// FingerHttpsTransport 通过指纹TLS连接发送HTTPS请求的 http.RoundTripper。
+// 连接按目标地址复用: 协商出 h2 的连接由 http2.ClientConn 多路复用，其余连接交给内部的 http.Transport 连接池。
+// 应创建一次后重复使用，空闲连接在 90 秒后自动关闭，也可以调用 CloseIdleConnections 立即关闭
 type FingerHttpsTransport struct {
 	dialer tls.ITLSDialer
+	h1     *http.Transport
+	h2     *http2.Transport
+
+	mu      sync.Mutex
+	h2Conns map[string]*http2.ClientConn
+	h1Addrs map[string]bool // 协商结果为 HTTP/1.1 的地址，之后的请求直接交给 h1
+}
+
+// connHandoffKey 请求 ctx 中携带 connHandoff 的键
+type connHandoffKey struct{}
+
+// connHandoff 将 RoundTrip 中已完成握手的连接交给 h1 的拨号函数，连接只能被取走一次
+type connHandoff struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (h *connHandoff) take() net.Conn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conn := h.conn
+	h.conn = nil
+	return conn
 }
 
 func NewFingerHttpsTransport(dialer tls.ITLSDialer) *FingerHttpsTransport {
-	return &FingerHttpsTransport{
-		dialer: dialer,
+	t := &FingerHttpsTransport{
+		dialer:  dialer,
+		h2:      &http2.Transport{IdleConnTimeout: idleConnTimeout},
+		h2Conns: make(map[string]*http2.ClientConn),
+		h1Addrs: make(map[string]bool),
 	}
+	t.h1 = &http.Transport{
+		DialTLSContext:  t.dialHTTP1,
+		IdleConnTimeout: idleConnTimeout,
+	}
+	return t
 }
 
 func (t *FingerHttpsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	hostWithPort := req.URL.Hostname() + ":" + req.URL.Port()
+	port := req.URL.Port()
+	if port == "" {
+		port = "443"
+	}
+	hostWithPort := net.JoinHostPort(req.URL.Hostname(), port)
+
+	t.mu.Lock()
+	cc := t.h2Conns[hostWithPort]
+	if cc != nil && !cc.CanTakeNewRequest() {
+		delete(t.h2Conns, hostWithPort)
+		cc = nil
+	}
+	http1 := t.h1Addrs[hostWithPort]
+	t.mu.Unlock()
+	if cc != nil {
+		return cc.RoundTrip(req)
+	}
+	if http1 {
+		return t.h1.RoundTrip(req)
+	}
+
 	tlsConn, err := t.dialer.DialTLS(req.Context(), "tcp", hostWithPort)
 	if err != nil {
 		return nil, err
 	}
-	var tripper http.RoundTripper
-	switch tlsConn.(*utls.UConn).ConnectionState().NegotiatedProtocol {
-	case "h2":
-		req.Proto = "HTTP/2.0"
-		req.ProtoMajor = 2
-		req.ProtoMinor = 0
-		tripper = &http2.Transport{
-			DialTLSContext: func(ctx context.Context, network, addr string, cfg *ctls.Config) (net.Conn, error) {
-				return tlsConn, nil
-			},
+	if tlsConn.NegotiatedProtocol() != "h2" {
+		t.mu.Lock()
+		t.h1Addrs[hostWithPort] = true
+		t.mu.Unlock()
+
+		// 连接随本次请求的 ctx 交给 h1 的拨号函数；h1 复用了空闲连接而没有拨号时，连接不会被取走，在这里关闭
+		handoff := &connHandoff{conn: tlsConn}
+		resp, err := t.h1.RoundTrip(req.WithContext(context.WithValue(req.Context(), connHandoffKey{}, handoff)))
+		if conn := handoff.take(); conn != nil {
+			conn.Close()
 		}
-	default:
-		req.Proto = "HTTP/1.1"
-		req.ProtoMajor = 1
-		req.ProtoMinor = 0
-		tripper = &http.Transport{
-			DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				return tlsConn, nil
-			},
+		return resp, err
+	}
+
+	cc, err = t.h2.NewClientConn(tlsConn)
+	if err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	t.mu.Lock()
+	if existing := t.h2Conns[hostWithPort]; existing != nil && existing.CanTakeNewRequest() {
+		// 并发请求已经建立了可用的连接，关闭多余的连接
+		t.mu.Unlock()
+		cc.Close()
+		return existing.RoundTrip(req)
+	}
+	t.h2Conns[hostWithPort] = cc
+	t.mu.Unlock()
+	return cc.RoundTrip(req)
+}
+
+// dialHTTP1 为 h1 建立连接，优先使用 RoundTrip 随请求交来的已完成握手的连接。
+// http.Transport 拨号时使用的 ctx 保留了请求 ctx 中的值
+func (t *FingerHttpsTransport) dialHTTP1(ctx context.Context, network, addr string) (net.Conn, error) {
+	if handoff, ok := ctx.Value(connHandoffKey{}).(*connHandoff); ok {
+		if conn := handoff.take(); conn != nil {
+			return conn, nil
+		}
+	}
+
+	tlsConn, err := t.dialer.DialTLS(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConn.NegotiatedProtocol() == "h2" {
+		tlsConn.Close()
+		return nil, errors.New("服务器改为协商 h2，无法作为 HTTP/1.1 连接使用")
+	}
+	return tlsConn, nil
+}
+
+// CloseIdleConnections 关闭没有进行中请求的连接，http.Client.CloseIdleConnections 会调用该方法
+func (t *FingerHttpsTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, cc := range t.h2Conns {
+		if cc.State().StreamsActive == 0 {
+			cc.Close()
+			delete(t.h2Conns, addr)
 		}
 	}
-	return tripper.RoundTrip(req)
 }
@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package transport
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/tls"
+)
+
+// countingDialer 记录建立的TLS连接数
+type countingDialer struct {
+	tls.ITLSDialer
+	dials atomic.Int32
+}
+
+func (d *countingDialer) DialTLS(ctx context.Context, network, addr string) (tls.IFingerConn, error) {
+	d.dials.Add(1)
+	return d.ITLSDialer.DialTLS(ctx, network, addr)
+}
+
+// trackConns 统计服务器上仍未关闭的连接数
+type trackConns struct {
+	mu   sync.Mutex
+	open map[net.Conn]bool
+}
+
+func (c *trackConns) connState(conn net.Conn, state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		c.open[conn] = true
+	case http.StateClosed, http.StateHijacked:
+		delete(c.open, conn)
+	}
+}
+
+func (c *trackConns) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.open)
+}
+
+func TestFingerHttpsTransportReusesConnections(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		wantProto string
+	}{
+		{"HTTP/2", true, "HTTP/2.0"},
+		{"HTTP/1.1", false, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conns := &trackConns{open: make(map[net.Conn]bool)}
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, r.Proto)
+			}))
+			srv.EnableHTTP2 = tt.http2
+			srv.Config.ConnState = conns.connState
+			srv.StartTLS()
+			defer srv.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+			base, err := tls.NewTLSDialerE(tls.WithLogger(logging.NewFakeLogger()), tls.WithRootCAs(roots))
+			if err != nil {
+				t.Fatal(err)
+			}
+			dialer := &countingDialer{ITLSDialer: base}
+			rt := NewFingerHttpsTransport(dialer)
+			client := &http.Client{Transport: rt}
+
+			for range 5 {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != tt.wantProto {
+					t.Fatalf("服务器收到的协议 = %s, want %s", body, tt.wantProto)
+				}
+			}
+			if n := dialer.dials.Load(); n != 1 {
+				t.Errorf("5 次请求建立了 %d 个连接, want 1", n)
+			}
+
+			client.CloseIdleConnections()
+			deadline := time.Now().Add(2 * time.Second)
+			for conns.count() > 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("CloseIdleConnections 后服务器仍有 %d 个连接未关闭", conns.count())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// gatedDialer 第一个连接立即返回，之后的连接在两个拨号都完成握手且 release 关闭后才返回
+type gatedDialer struct {
+	tls.ITLSDialer
+	calls   atomic.Int32
+	started sync.WaitGroup
+	release chan struct{}
+}
+
+func (d *gatedDialer) DialTLS(ctx context.Context, network, addr string) (tls.IFingerConn, error) {
+	conn, err := d.ITLSDialer.DialTLS(ctx, network, addr)
+	d.started.Done()
+	if d.calls.Add(1) > 1 {
+		<-d.release
+	}
+	return conn, err
+}
+
+func TestFingerHttpsTransportHandoffNoLeak(t *testing.T) {
+	conns := &trackConns{open: make(map[net.Conn]bool)}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	}))
+	srv.Config.ConnState = conns.connState
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	base, err := tls.NewTLSDialerE(tls.WithLogger(logging.NewFakeLogger()), tls.WithRootCAs(roots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialer := &gatedDialer{ITLSDialer: base, release: make(chan struct{})}
+	dialer.started.Add(2)
+	client := &http.Client{Transport: NewFingerHttpsTransport(dialer)}
+	defer client.CloseIdleConnections()
+
+	get := func() error {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		return resp.Body.Close()
+	}
+
+	// 两个请求都在协议记录之前各自完成握手；先返回的请求结束后连接进入 h1 的空闲连接池，
+	// 另一个请求随后直接复用该空闲连接，它自己握手完成的连接不会被 h1 取用
+	results := make(chan error, 2)
+	for range 2 {
+		go func() { results <- get() }()
+	}
+	dialer.started.Wait()
+	if err := <-results; err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	close(dialer.release)
+	if err := <-results; err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for conns.count() > 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("请求结束后服务器仍有 %d 个连接, want 1 (未被 h1 取用的连接应被关闭)", conns.count())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
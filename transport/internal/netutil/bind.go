@@ -0,0 +1,33 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package netutil
+
+import "net"
+
+// NewDialer 返回绑定源地址和网络接口的 net.Dialer。
+// localIP 为空时由系统选择源地址，ifname 为空时不绑定网络接口
+func NewDialer(localIP net.IP, ifname string) *net.Dialer {
+	d := &net.Dialer{}
+	if localIP != nil {
+		d.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	if ifname != "" {
+		d.Control = bindToDevice(ifname)
+	}
+	return d
+}
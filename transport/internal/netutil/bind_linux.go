@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package netutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// bindToDevice 通过 SO_BINDTODEVICE 将套接字绑定到指定网络接口，通常需要 CAP_NET_RAW 权限
+func bindToDevice(ifname string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, ifname)
+		})
+		if err == nil {
+			err = sockErr
+		}
+		if err != nil {
+			return fmt.Errorf("绑定网络接口 %s 失败: %w", ifname, err)
+		}
+		return nil
+	}
+}
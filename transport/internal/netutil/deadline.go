@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// aLongTimeAgo 用于立即中断阻塞中的读写
+var aLongTimeAgo = time.Unix(1, 0)
+
+// WatchContext 将 ctx 的截止时间设置为 conn 的读写截止时间，并在 ctx 取消时立即中断阻塞中的读写。
+// 阶段结束后必须调用返回的 stop: 它清除截止时间并停止监听，若 ctx 已经取消则返回 ctx.Err()，
+// 此时连接可能处于读写中断后的状态，调用方应关闭连接
+func WatchContext(ctx context.Context, conn net.Conn) (stop func() error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stopWatch := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(aLongTimeAgo)
+	})
+
+	return func() error {
+		if !stopWatch() {
+			return ctx.Err()
+		}
+		return conn.SetDeadline(time.Time{})
+	}
+}
+
+// ContextError ctx 已结束时返回 ctx.Err()，否则返回 err。
+// 读写因截止时间被中断时返回的是 i/o timeout，用 ctx 的错误代替更能反映实际原因。
+// 连接的截止时间与 ctx 相同，读写可能先于 ctx 感知到超时，此时 ctx.Err() 仍为空，
+// 只要 ctx 的截止时间已过同样返回 context.DeadlineExceeded
+func ContextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+	}
+	return err
+}
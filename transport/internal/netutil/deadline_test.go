@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package netutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// expiredContext 截止时间已过但尚未被标记为结束的 ctx，模拟连接先于 ctx 感知到超时的情况
+type expiredContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c expiredContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func TestContextError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ioErr := errors.New("connection reset")
+	timeoutErr := fmt.Errorf("读取响应失败: %w", os.ErrDeadlineExceeded)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want error
+	}{
+		{"ctx未结束", context.Background(), ioErr, ioErr},
+		{"ctx已取消", canceled, ioErr, context.Canceled},
+		{"截止时间已过但ctx未结束", expiredContext{context.Background(), time.Now().Add(-time.Millisecond)}, timeoutErr, context.DeadlineExceeded},
+		{"截止时间未到", expiredContext{context.Background(), time.Now().Add(time.Hour)}, timeoutErr, timeoutErr},
+		{"截止时间已过但不是超时错误", expiredContext{context.Background(), time.Now().Add(-time.Millisecond)}, ioErr, ioErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContextError(tt.ctx, tt.err); !errors.Is(got, tt.want) {
+				t.Errorf("ContextError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
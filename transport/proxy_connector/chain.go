@@ -0,0 +1,180 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package proxy_connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// HopError 代理链中某一跳连接失败
+type HopError struct {
+	// Index 失败的代理在链中的位置，从0开始
+	Index int
+	Proxy *url.URL
+	Err   error
+}
+
+func (e *HopError) Error() string {
+	return fmt.Sprintf("代理链第 %d 跳 (%s) 连接失败: %v", e.Index+1, e.Proxy.Redacted(), e.Err)
+}
+
+func (e *HopError) Unwrap() error {
+	return e.Err
+}
+
+// ChainProxyConnector 依次通过多个代理建立隧道，第一跳使用配置的拨号函数连接代理服务器，
+// 每一跳都通过 ProxyHandshaker 在上一跳建立的连接上协商，通过 Register 注册的协议 (包括内置的
+// HTTP CONNECT、HTTPS 与 SOCKS5) 可以任意混用。未实现 ProxyHandshaker 的连接器只能作为第一跳，通过其 Connect 连接。
+// 每一跳使用代理URL中的认证信息；超时默认为 timeout，可通过URL参数 timeout (如 "?timeout=5s") 单独设置
+type ChainProxyConnector struct {
+	timeout time.Duration
+	logger  logging.ILogger
+	dial    DialFunc
+	tlsWrap TLSWrapFunc
+}
+
+func NewChainProxyConnector(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) *ChainProxyConnector {
+	options := NewConnectorOptions(opts...)
+
+	return &ChainProxyConnector{
+		timeout: timeout,
+		logger:  logger,
+		dial:    options.dial,
+		tlsWrap: options.tlsWrap,
+	}
+}
+
+// Connect 通过单个代理连接到目标地址
+func (c *ChainProxyConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	return c.ConnectChain(ctx, []*url.URL{proxyURL}, targetAddr)
+}
+
+// Handshake 在已建立的连接上通过单个代理协商，失败时不关闭连接
+func (c *ChainProxyConnector) Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	connector, timeout, err := c.hopConnector(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	handshaker, ok := connector.(ProxyHandshaker)
+	if !ok {
+		return nil, errNoHandshake(proxyURL)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return handshaker.Handshake(ctx, conn, proxyURL, targetAddr)
+}
+
+// ConnectChain 依次通过 chain 中的代理连接到目标地址，失败时返回 *HopError
+func (c *ChainProxyConnector) ConnectChain(ctx context.Context, chain []*url.URL, targetAddr string) (net.Conn, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("代理链为空")
+	}
+
+	var conn net.Conn
+	for i, hop := range chain {
+		next := targetAddr
+		if i+1 < len(chain) {
+			next = chain[i+1].Host
+		}
+
+		var err error
+		conn, err = c.connectHop(ctx, conn, hop, next)
+		if err != nil {
+			hopErr := &HopError{Index: i, Proxy: hop, Err: err}
+			c.logger.Error("代理链连接失败", hopErr)
+			return nil, hopErr
+		}
+		if len(chain) > 1 {
+			c.logger.Info(fmt.Sprintf("[CHAIN] 第 %d/%d 跳: 已通过 %s 连接到 %s", i+1, len(chain), hop.Redacted(), next))
+		}
+	}
+	return conn, nil
+}
+
+// connectHop 通过一跳代理连接到 next。conn 为空时使用拨号函数连接代理服务器，否则在上一跳建立的隧道上协商；
+// 未实现 ProxyHandshaker 的连接器只能作为第一跳，直接调用其 Connect。超时覆盖本跳的连接和协商，失败时关闭连接
+func (c *ChainProxyConnector) connectHop(ctx context.Context, conn net.Conn, hop *url.URL, next string) (net.Conn, error) {
+	connector, timeout, err := c.hopConnector(hop)
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, err
+	}
+	handshaker, ok := connector.(ProxyHandshaker)
+	if !ok && conn != nil {
+		conn.Close()
+		return nil, errNoHandshake(hop)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if !ok {
+		return connector.Connect(ctx, hop, next)
+	}
+
+	if conn == nil {
+		// 第一跳由链统一拨号，自定义连接器同样使用配置的拨号函数
+		c.logger.Info(fmt.Sprintf("[UPSTREAM] 连接到代理服务器 %s", hop.Host))
+		conn, err = c.dial(ctx, "tcp", hop.Host)
+		if err != nil {
+			c.logger.Error(fmt.Sprintf("连接代理服务器 %s 失败", hop.Host), err)
+			return nil, err
+		}
+	}
+	tunnel, err := handshaker.Handshake(ctx, conn, hop, next)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+func errNoHandshake(hop *url.URL) error {
+	return fmt.Errorf("代理协议 %s 的连接器未实现 ProxyHandshaker，只能作为代理链的第一跳", hop.Scheme)
+}
+
+// hopConnector 从注册表中按代理协议创建单跳的连接器，并返回本跳的超时时间
+func (c *ChainProxyConnector) hopConnector(hop *url.URL) (ProxyConnector, time.Duration, error) {
+	timeout := c.timeout
+	if v := hop.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, 0, fmt.Errorf("无效的超时时间 %q: %w", v, err)
+		}
+		timeout = d
+	}
+
+	ctor, ok := Lookup(hop.Scheme)
+	if !ok {
+		return nil, 0, fmt.Errorf("不支持的代理协议: %s", hop.Scheme)
+	}
+	// 超时由调用方统一控制
+	return ctor(0, c.logger, WithDialFunc(c.dial), WithTLSWrapFunc(c.tlsWrap)), timeout, nil
+}
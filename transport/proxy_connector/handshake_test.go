@@ -0,0 +1,439 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package proxy_connector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// pipeProxy 通过 net.Pipe 连接连接器与模拟的代理服务器，serve 在服务端协程中运行，
+// 返回的 done 在 serve 结束后关闭
+func pipeProxy(t *testing.T, serve func(conn net.Conn)) (client net.Conn, done <-chan struct{}) {
+	t.Helper()
+	client, server := net.Pipe()
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer server.Close()
+		serve(server)
+	}()
+	t.Cleanup(func() {
+		client.Close()
+		<-finished
+	})
+	return client, finished
+}
+
+// serveCONNECT 读取CONNECT请求并返回 status 状态码，成功时把隧道中收到的第一行回显给客户端
+func serveCONNECT(t *testing.T, conn net.Conn, status int, gotTarget, gotAuth *string) {
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Errorf("读取CONNECT请求失败: %v", err)
+		return
+	}
+	if req.Method != http.MethodConnect {
+		t.Errorf("请求方法 = %s, want CONNECT", req.Method)
+	}
+	*gotTarget = req.Host
+	*gotAuth = req.Header.Get("Proxy-Authorization")
+	if _, err := io.WriteString(conn, "HTTP/1.1 "+strconv.Itoa(status)+" "+http.StatusText(status)+"\r\n\r\n"); err != nil {
+		t.Errorf("发送CONNECT响应失败: %v", err)
+		return
+	}
+	if status != http.StatusOK {
+		return
+	}
+	line, err := br.ReadString('\n')
+	if err != nil {
+		t.Errorf("读取隧道数据失败: %v", err)
+		return
+	}
+	io.WriteString(conn, line)
+}
+
+// checkTunnel 通过隧道发送一行数据并检查回显
+func checkTunnel(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("写入隧道失败: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("隧道回显 = %q, %v", line, err)
+	}
+}
+
+func TestHTTPHandshake(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *url.Userinfo
+		status   int
+		wantAuth string
+		wantErr  bool
+	}{
+		{name: "无认证", status: http.StatusOK},
+		{name: "Basic认证", user: url.UserPassword("alice", "secret"), status: http.StatusOK, wantAuth: "Basic YWxpY2U6c2VjcmV0"},
+		{name: "只有用户名不发送认证", user: url.User("alice"), status: http.StatusOK},
+		{name: "需要认证", status: http.StatusProxyAuthRequired, wantErr: true},
+		{name: "拒绝连接", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotTarget, gotAuth string
+			conn, done := pipeProxy(t, func(conn net.Conn) {
+				serveCONNECT(t, conn, tt.status, &gotTarget, &gotAuth)
+			})
+
+			connector := NewHTTPProxyConnector(time.Second, logging.NewFakeLogger()).(ProxyHandshaker)
+			proxyURL := &url.URL{Scheme: "http", Host: "proxy.example:8080", User: tt.user}
+			tunnel, err := connector.Handshake(context.Background(), conn, proxyURL, "example.com:443")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Handshake() 应返回错误")
+				}
+				if !strings.Contains(err.Error(), strconv.Itoa(tt.status)) {
+					t.Errorf("Handshake() error = %v, 缺少状态码 %d", err, tt.status)
+				}
+				<-done
+				return
+			}
+			if err != nil {
+				t.Fatalf("Handshake() error = %v", err)
+			}
+			if tunnel != conn {
+				t.Error("HTTP代理的 Handshake() 应返回传入的连接")
+			}
+			checkTunnel(t, tunnel)
+			<-done
+
+			if gotTarget != "example.com:443" {
+				t.Errorf("CONNECT目标 = %q, want example.com:443", gotTarget)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("Proxy-Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestHTTPHandshakeContextCanceled(t *testing.T) {
+	// 代理服务器读取请求后不响应，取消 ctx 后 Handshake 应立即返回
+	conn, _ := pipeProxy(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	connector := NewHTTPProxyConnector(0, logging.NewFakeLogger()).(ProxyHandshaker)
+	_, err := connector.Handshake(ctx, conn, &url.URL{Scheme: "http", Host: "proxy.example:8080"}, "example.com:443")
+	if err != context.Canceled {
+		t.Errorf("Handshake() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+// socks5Request 模拟的SOCKS5代理服务器收到的请求
+type socks5Request struct {
+	methods  []byte
+	user     string
+	password string
+	target   string
+}
+
+// serveSOCKS5 模拟SOCKS5代理服务器，auth 非空时要求用户名密码认证并与之比较，
+// reply 为连接请求的响应码，成功时回显隧道中的第一行
+func serveSOCKS5(t *testing.T, conn net.Conn, auth *url.Userinfo, reply byte, got *socks5Request) {
+	readN := func(n int) []byte {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Errorf("读取SOCKS5请求失败: %v", err)
+			return nil
+		}
+		return buf
+	}
+
+	head := readN(2)
+	if head == nil {
+		return
+	}
+	if head[0] != socks5Version {
+		t.Errorf("SOCKS5版本 = %d", head[0])
+	}
+	if got.methods = readN(int(head[1])); got.methods == nil {
+		return
+	}
+	method := byte(authNone)
+	if auth != nil {
+		method = authPassword
+		if bytes.IndexByte(got.methods, authPassword) < 0 {
+			method = authNoAcceptable
+		}
+	}
+	conn.Write([]byte{socks5Version, method})
+	switch method {
+	case authNoAcceptable:
+		return
+	case authPassword:
+		b := readN(2)
+		if b == nil {
+			return
+		}
+		got.user = string(readN(int(b[1])))
+		got.password = string(readN(int(readN(1)[0])))
+		password, _ := auth.Password()
+		if got.user != auth.Username() || got.password != password {
+			conn.Write([]byte{0x01, 0x01})
+			return
+		}
+		conn.Write([]byte{0x01, 0x00})
+	}
+
+	req := readN(4)
+	if req == nil {
+		return
+	}
+	if req[1] != cmdConnect {
+		t.Errorf("SOCKS5命令 = %d, want CONNECT", req[1])
+	}
+	var host string
+	switch req[3] {
+	case addrTypeIPv4:
+		host = net.IP(readN(4)).String()
+	case addrTypeIPv6:
+		host = net.IP(readN(16)).String()
+	case addrTypeDomain:
+		host = string(readN(int(readN(1)[0])))
+	}
+	port := binary.BigEndian.Uint16(readN(2))
+	got.target = net.JoinHostPort(host, strconv.Itoa(int(port)))
+
+	// 响应中使用域名类型的绑定地址，检查客户端能正确跳过
+	resp := []byte{socks5Version, reply, 0x00, addrTypeDomain, 5}
+	resp = append(resp, "proxy"...)
+	resp = append(resp, 0x04, 0x38)
+	conn.Write(resp)
+	if reply != respSucceeded {
+		return
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Errorf("读取隧道数据失败: %v", err)
+		return
+	}
+	io.WriteString(conn, line)
+}
+
+func TestSocks5Handshake(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        *url.Userinfo // 客户端代理URL中的认证信息
+		auth        *url.Userinfo // 服务端要求的认证信息
+		target      string
+		reply       byte
+		wantMethods []byte
+		wantErr     string
+	}{
+		{name: "无认证/域名", target: "example.com:443", wantMethods: []byte{authNone}},
+		{name: "无认证/IPv4", target: "192.0.2.1:8443", wantMethods: []byte{authNone}},
+		{name: "无认证/IPv6", target: "[2001:db8::1]:443", wantMethods: []byte{authNone}},
+		{
+			name: "用户名密码认证", user: url.UserPassword("alice", "secret"), auth: url.UserPassword("alice", "secret"),
+			target: "example.com:443", wantMethods: []byte{authNone, authPassword},
+		},
+		{
+			name: "认证失败", user: url.UserPassword("alice", "wrong"), auth: url.UserPassword("alice", "secret"),
+			target: "example.com:443", wantMethods: []byte{authNone, authPassword}, wantErr: "SOCKS5认证失败",
+		},
+		{
+			name: "服务端要求认证", auth: url.UserPassword("alice", "secret"),
+			target: "example.com:443", wantMethods: []byte{authNone}, wantErr: "不支持任何认证方法",
+		},
+		{
+			name: "连接被拒绝", target: "example.com:443", reply: 0x05,
+			wantMethods: []byte{authNone}, wantErr: "SOCKS5连接失败，状态码: 5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got socks5Request
+			conn, done := pipeProxy(t, func(conn net.Conn) {
+				serveSOCKS5(t, conn, tt.auth, tt.reply, &got)
+			})
+
+			connector := NewSocks5ProxyConnector(time.Second, logging.NewFakeLogger()).(ProxyHandshaker)
+			proxyURL := &url.URL{Scheme: "socks5", Host: "proxy.example:1080", User: tt.user}
+			tunnel, err := connector.Handshake(context.Background(), conn, proxyURL, tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Handshake() error = %v, want %q", err, tt.wantErr)
+				}
+				conn.Close()
+				<-done
+			} else {
+				if err != nil {
+					t.Fatalf("Handshake() error = %v", err)
+				}
+				if tunnel != conn {
+					t.Error("SOCKS5代理的 Handshake() 应返回传入的连接")
+				}
+				checkTunnel(t, tunnel)
+				<-done
+			}
+
+			if !bytes.Equal(got.methods, tt.wantMethods) {
+				t.Errorf("认证方法 = %v, want %v", got.methods, tt.wantMethods)
+			}
+			if tt.user != nil && tt.auth != nil {
+				password, _ := tt.user.Password()
+				if got.user != tt.user.Username() || got.password != password {
+					t.Errorf("认证信息 = %s:%s, want %s", got.user, got.password, tt.user)
+				}
+			}
+			if tt.wantErr == "" && got.target != tt.target {
+				t.Errorf("连接目标 = %q, want %q", got.target, tt.target)
+			}
+		})
+	}
+}
+
+// newTestCertificate 生成 name 的自签名证书
+func newTestCertificate(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+func TestHTTPSHandshake(t *testing.T) {
+	cert, roots := newTestCertificate(t, "proxy.example")
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "成功", status: http.StatusOK},
+		{name: "拒绝连接", status: http.StatusForbidden, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var serverName, gotTarget, gotAuth string
+			conn, done := pipeProxy(t, func(conn net.Conn) {
+				// 只关闭底层连接: net.Pipe 没有缓冲，双方同时发送 close_notify 会互相阻塞
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+				if err := tlsConn.Handshake(); err != nil {
+					t.Errorf("代理服务器TLS握手失败: %v", err)
+					return
+				}
+				serverName = tlsConn.ConnectionState().ServerName
+				serveCONNECT(t, tlsConn, tt.status, &gotTarget, &gotAuth)
+			})
+
+			// 使用测试证书校验代理服务器，其余与默认的TLS握手相同
+			wrap := func(ctx context.Context, conn net.Conn, name string) (net.Conn, error) {
+				tlsConn := tls.Client(conn, &tls.Config{ServerName: name, RootCAs: roots})
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					return nil, err
+				}
+				return tlsConn, nil
+			}
+			connector := NewHTTPSProxyConnector(time.Second, logging.NewFakeLogger(), WithTLSWrapFunc(wrap)).(ProxyHandshaker)
+			proxyURL := &url.URL{Scheme: "https", Host: "proxy.example:8443", User: url.UserPassword("alice", "secret")}
+			tunnel, err := connector.Handshake(context.Background(), conn, proxyURL, "example.com:443")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Handshake() 应返回错误")
+				}
+				<-done
+				return
+			}
+			if err != nil {
+				t.Fatalf("Handshake() error = %v", err)
+			}
+			if _, ok := tunnel.(*tls.Conn); !ok {
+				t.Errorf("HTTPS代理的 Handshake() 返回 %T, want *tls.Conn", tunnel)
+			}
+			checkTunnel(t, tunnel)
+			tunnel.Close()
+			<-done
+
+			if serverName != "proxy.example" {
+				t.Errorf("代理服务器收到的SNI = %q, want proxy.example", serverName)
+			}
+			if gotTarget != "example.com:443" || gotAuth != "Basic YWxpY2U6c2VjcmV0" {
+				t.Errorf("CONNECT请求 = %q %q", gotTarget, gotAuth)
+			}
+		})
+	}
+}
+
+func TestHTTPSHandshakeUntrustedProxy(t *testing.T) {
+	cert, _ := newTestCertificate(t, "proxy.example")
+	conn, _ := pipeProxy(t, func(conn net.Conn) {
+		tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+	})
+
+	// 默认的TLS握手使用系统根证书，不信任自签名证书
+	connector := NewHTTPSProxyConnector(time.Second, logging.NewFakeLogger()).(ProxyHandshaker)
+	_, err := connector.Handshake(context.Background(), conn, &url.URL{Scheme: "https", Host: "proxy.example:8443"}, "example.com:443")
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Errorf("Handshake() error = %v, want x509.UnknownAuthorityError", err)
+	}
+}
@@ -28,41 +28,79 @@ import (
 	"time"
 
 	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/internal/netutil"
 )
 
 // HttpProxyConnector 实现HTTP代理连接
 type HttpProxyConnector struct {
 	timeout time.Duration
 	logger  logging.ILogger
+	dial    DialFunc
 }
 
-func NewHTTPProxyConnector(timeout time.Duration, logger logging.ILogger) ProxyConnector {
+func NewHTTPProxyConnector(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) ProxyConnector {
+	options := NewConnectorOptions(opts...)
+
 	return &HttpProxyConnector{
 		timeout: timeout,
 		logger:  logger,
+		dial:    options.dial,
 	}
 }
 
 func (c *HttpProxyConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
 	c.logger.Info(fmt.Sprintf("[UPSTREAM] 连接到代理服务器 %s", proxyURL.Host))
 
+	// 超时覆盖连接代理服务器和CONNECT协商两个阶段
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
 	// 连接到代理服务器
-	dialer := &net.Dialer{Timeout: c.timeout}
-	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	conn, err := c.dial(ctx, "tcp", proxyURL.Host)
 	if err != nil {
 		c.logger.Error(fmt.Sprintf("连接代理服务器 %s 失败", proxyURL.Host), err)
 		return nil, err
 	}
 
+	if err := c.handshake(ctx, conn, proxyURL, targetAddr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Handshake 在已建立的连接上发送CONNECT请求，失败时不关闭连接
+func (c *HttpProxyConnector) Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if err := c.handshake(ctx, conn, proxyURL, targetAddr); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *HttpProxyConnector) handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) error {
 	// 发送CONNECT请求
+	stop := netutil.WatchContext(ctx, conn)
 	if err := c.sendConnectRequest(conn, targetAddr, proxyURL); err != nil {
-		conn.Close()
+		stop()
+		err = netutil.ContextError(ctx, err)
 		c.logger.Error(fmt.Sprintf("发送CONNECT请求到 %s 失败", proxyURL.Host), err)
-		return nil, err
+		return err
+	}
+	if err := stop(); err != nil {
+		c.logger.Error(fmt.Sprintf("发送CONNECT请求到 %s 失败", proxyURL.Host), err)
+		return err
 	}
 
 	c.logger.Info(fmt.Sprintf("[UPSTREAM] 成功建立到 %s 的隧道连接", targetAddr))
-	return conn, nil
+	return nil
 }
 
 func (c *HttpProxyConnector) sendConnectRequest(conn net.Conn, targetAddr string, proxyURL *url.URL) error {
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package proxy_connector
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// HttpsProxyConnector 实现HTTPS代理连接，先与代理服务器进行TLS握手，再在TLS连接上发送CONNECT请求
+type HttpsProxyConnector struct {
+	timeout time.Duration
+	logger  logging.ILogger
+	dial    DialFunc
+	tlsWrap TLSWrapFunc
+	http    *HttpProxyConnector
+}
+
+func NewHTTPSProxyConnector(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) ProxyConnector {
+	options := NewConnectorOptions(opts...)
+
+	return &HttpsProxyConnector{
+		timeout: timeout,
+		logger:  logger,
+		dial:    options.dial,
+		tlsWrap: options.tlsWrap,
+		http:    &HttpProxyConnector{logger: logger},
+	}
+}
+
+func (c *HttpsProxyConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	c.logger.Info(fmt.Sprintf("[UPSTREAM] 连接到HTTPS代理服务器 %s", proxyURL.Host))
+
+	// 超时覆盖连接代理服务器、TLS握手和CONNECT协商三个阶段
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	conn, err := c.dial(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("连接HTTPS代理服务器 %s 失败", proxyURL.Host), err)
+		return nil, err
+	}
+
+	tunnel, err := c.negotiate(ctx, conn, proxyURL, targetAddr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// Handshake 在已建立的连接上与代理服务器进行TLS握手并发送CONNECT请求，返回TLS连接，失败时不关闭连接
+func (c *HttpsProxyConnector) Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.negotiate(ctx, conn, proxyURL, targetAddr)
+}
+
+func (c *HttpsProxyConnector) negotiate(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	tlsConn, err := c.tlsWrap(ctx, conn, proxyURL.Hostname())
+	if err != nil {
+		c.logger.Error(fmt.Sprintf("与HTTPS代理服务器 %s 进行TLS握手失败", proxyURL.Host), err)
+		return nil, fmt.Errorf("与HTTPS代理服务器 %s 进行TLS握手失败: %w", proxyURL.Host, err)
+	}
+
+	if err := c.http.handshake(ctx, tlsConn, proxyURL, targetAddr); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// defaultTLSWrap 使用 crypto/tls 和系统根证书与代理服务器握手，CONNECT 只能在 HTTP/1.1 上进行
+func defaultTLSWrap(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName: serverName,
+		NextProtos: []string{"http/1.1"},
+	})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -21,6 +21,8 @@ import (
 	"context"
 	"net"
 	"net/url"
+
+	"github.com/aberstone/fingertls/transport/internal/netutil"
 )
 
 type ProxyScheme string
@@ -28,9 +30,93 @@ type ProxyScheme string
 const (
 	ProxySchemeHTTP   ProxyScheme = "http"
 	ProxySchemeSocks5 ProxyScheme = "socks5"
+	ProxySchemeHTTPS  ProxyScheme = "https"
 )
 
 type ProxyConnector interface {
 	// Connect 建立到目标地址的代理连接
 	Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error)
 }
+
+// ProxyHandshaker 在已建立的连接上进行代理协议协商，可用于自定义拨号、代理链以及基于 net.Pipe 的测试。
+// ProxyConnector 可以选择实现该接口: 实现后可以作为代理链中的任意一跳，且第一跳使用拨号器建立的连接；
+// 未实现时只能作为代理链的第一跳，由 Connect 自行连接代理服务器。协商失败时由调用方关闭连接
+type ProxyHandshaker interface {
+	// Handshake 通过 conn 请求代理服务器连接到目标地址，返回到目标地址的隧道连接。
+	// HTTP 与 SOCKS5 代理返回 conn 本身，HTTPS 代理返回包装了 conn 的TLS连接
+	Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error)
+}
+
+// DialFunc 建立到代理服务器的底层连接
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// TLSWrapFunc 在到HTTPS代理服务器的连接上完成TLS握手，serverName 为代理服务器的主机名
+type TLSWrapFunc func(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error)
+
+// ConnectorOptions 代理连接器的配置，由 ConnectorOption 设置。
+// 通过 RegisterWithOptions 注册的自定义连接器可用 NewConnectorOptions 读取TLSDialer传入的拨号和TLS握手函数
+type ConnectorOptions struct {
+	dial          DialFunc
+	tlsWrap       TLSWrapFunc
+	localAddr     net.IP
+	bindInterface string
+}
+
+// ConnectorOption 代理连接器的可选配置
+type ConnectorOption func(*ConnectorOptions)
+
+// NewConnectorOptions 应用可选配置，未设置拨号函数时按源地址和网络接口配置创建，
+// 未设置TLS握手函数时使用 crypto/tls 和系统根证书
+func NewConnectorOptions(opts ...ConnectorOption) *ConnectorOptions {
+	options := &ConnectorOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.dial == nil {
+		options.dial = netutil.NewDialer(options.localAddr, options.bindInterface).DialContext
+	}
+	if options.tlsWrap == nil {
+		options.tlsWrap = defaultTLSWrap
+	}
+	return options
+}
+
+// Dial 建立到代理服务器的连接。由TLSDialer创建的连接器会使用拨号器的解析器、源地址绑定和 Happy Eyeballs
+func (o *ConnectorOptions) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	return o.dial(ctx, network, addr)
+}
+
+// WrapTLS 在到代理服务器的连接上完成TLS握手，serverName 为代理服务器的主机名
+func (o *ConnectorOptions) WrapTLS(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+	return o.tlsWrap(ctx, conn, serverName)
+}
+
+// WithDialFunc 设置连接代理服务器使用的拨号函数，可用于自定义域名解析、绑定本地地址等。
+// 设置后忽略 WithLocalAddr 和 WithBindInterface
+func WithDialFunc(dial DialFunc) ConnectorOption {
+	return func(opts *ConnectorOptions) {
+		opts.dial = dial
+	}
+}
+
+// WithTLSWrapFunc 设置与HTTPS代理服务器进行TLS握手的函数，可用于自定义证书校验或模拟指纹。
+// 默认使用 crypto/tls 和系统根证书
+func WithTLSWrapFunc(wrap TLSWrapFunc) ConnectorOption {
+	return func(opts *ConnectorOptions) {
+		opts.tlsWrap = wrap
+	}
+}
+
+// WithLocalAddr 绑定连接代理服务器使用的源地址
+func WithLocalAddr(ip net.IP) ConnectorOption {
+	return func(opts *ConnectorOptions) {
+		opts.localAddr = ip
+	}
+}
+
+// WithBindInterface 通过 SO_BINDTODEVICE 将到代理服务器的连接绑定到指定网络接口 (仅Linux)
+func WithBindInterface(name string) ConnectorOption {
+	return func(opts *ConnectorOptions) {
+		opts.bindInterface = name
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package proxy_connector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// ConnectorConstructor 创建代理连接器，超时为0时由调用方通过 ctx 控制。
+// 连接器实现 ProxyHandshaker 时，到代理服务器的连接由TLSDialer建立后交给 Handshake，
+// 因此只实现协议协商的连接器同样会使用拨号器的解析器、源地址绑定和 Happy Eyeballs；
+// 只实现 Connect 的连接器自行连接代理服务器，只能作为代理链的第一跳
+type ConnectorConstructor func(timeout time.Duration, logger logging.ILogger) ProxyConnector
+
+// ConnectorConstructorWithOptions 创建需要读取拨号或TLS握手函数的代理连接器，
+// 可通过 NewConnectorOptions(opts...) 获取TLSDialer传入的配置
+type ConnectorConstructorWithOptions func(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) ProxyConnector
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]ConnectorConstructorWithOptions)
+)
+
+func init() {
+	RegisterWithOptions(string(ProxySchemeHTTP), NewHTTPProxyConnector)
+	RegisterWithOptions(string(ProxySchemeHTTPS), NewHTTPSProxyConnector)
+	RegisterWithOptions(string(ProxySchemeSocks5), NewSocks5ProxyConnector)
+}
+
+// Register 注册代理协议的连接器，协议名不区分大小写，重复注册时覆盖之前的连接器 (包括内置协议)。
+// 通常在 init 中调用，ctor 为空时 panic
+func Register(scheme string, ctor ConnectorConstructor) {
+	if ctor == nil {
+		panic("proxy_connector: 注册的连接器为空: " + scheme)
+	}
+	RegisterWithOptions(scheme, func(timeout time.Duration, logger logging.ILogger, _ ...ConnectorOption) ProxyConnector {
+		return ctor(timeout, logger)
+	})
+}
+
+// RegisterWithOptions 与 Register 相同，构造函数可以接收拨号函数、TLS握手函数等配置
+func RegisterWithOptions(scheme string, ctor ConnectorConstructorWithOptions) {
+	if ctor == nil {
+		panic("proxy_connector: 注册的连接器为空: " + scheme)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(scheme)] = ctor
+}
+
+// Lookup 返回代理协议对应的连接器构造函数
+func Lookup(scheme string) (ConnectorConstructorWithOptions, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	ctor, ok := registry[strings.ToLower(scheme)]
+	return ctor, ok
+}
+
+// Schemes 返回已注册的代理协议，按名称排序
+func Schemes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package proxy_connector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// tunnelConnector 只实现协议协商的自定义连接器，在连接上写入一行 "TUNNEL <目标地址>"
+type tunnelConnector struct {
+	wrap func(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error)
+}
+
+func (c *tunnelConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	return nil, errors.New("代理链不应调用 Connect")
+}
+
+func (c *tunnelConnector) Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	if c.wrap != nil {
+		var err error
+		if conn, err = c.wrap(ctx, conn, proxyURL.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := conn.Write([]byte("TUNNEL " + targetAddr + "\n")); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connectOnlyConnector 只实现 Connect 的自定义连接器，自行拨号后写入一行 "TUNNEL <目标地址>"
+type connectOnlyConnector struct {
+	dial DialFunc
+}
+
+func (c *connectOnlyConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	conn, err := c.dial(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte("TUNNEL " + targetAddr + "\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// registerForTest 注册测试用协议，测试结束时移除
+func registerForTest(t *testing.T, scheme string, register func()) {
+	t.Helper()
+	register()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, scheme)
+		registryMu.Unlock()
+	})
+}
+
+// pipeDialer 返回记录拨号地址的拨号函数，服务端读取的第一行发送到 lines
+func pipeDialer(t *testing.T, dialed *[]string, lines chan<- string) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		*dialed = append(*dialed, addr)
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			line, _ := bufio.NewReader(server).ReadString('\n')
+			lines <- line
+		}()
+		t.Cleanup(func() { client.Close() })
+		return client, nil
+	}
+}
+
+func TestRegisterCustomScheme(t *testing.T) {
+	registerForTest(t, "testtunnel", func() {
+		Register("TestTunnel", func(timeout time.Duration, logger logging.ILogger) ProxyConnector {
+			return &tunnelConnector{}
+		})
+	})
+	if _, ok := Lookup("TESTTUNNEL"); !ok {
+		t.Fatal("Lookup() 未找到已注册的协议")
+	}
+	if !slices.Contains(Schemes(), "testtunnel") {
+		t.Errorf("Schemes() = %v, 缺少 testtunnel", Schemes())
+	}
+
+	// 第一跳使用链配置的拨号函数连接代理服务器
+	var dialed []string
+	lines := make(chan string, 1)
+	chain := NewChainProxyConnector(time.Second, logging.NewFakeLogger(), WithDialFunc(pipeDialer(t, &dialed, lines)))
+	conn, err := chain.ConnectChain(context.Background(), []*url.URL{{Scheme: "testtunnel", Host: "tunnel.example:9000"}}, "example.com:443")
+	if err != nil {
+		t.Fatalf("ConnectChain() error = %v", err)
+	}
+	defer conn.Close()
+
+	if len(dialed) != 1 || dialed[0] != "tunnel.example:9000" {
+		t.Errorf("拨号地址 = %v, want [tunnel.example:9000]", dialed)
+	}
+	if line := <-lines; line != "TUNNEL example.com:443\n" {
+		t.Errorf("代理服务器收到 %q", line)
+	}
+}
+
+func TestRegisterWithOptions(t *testing.T) {
+	registerForTest(t, "testwrapped", func() {
+		RegisterWithOptions("testwrapped", func(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) ProxyConnector {
+			return &tunnelConnector{wrap: NewConnectorOptions(opts...).WrapTLS}
+		})
+	})
+
+	var wrapped string
+	wrap := func(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+		wrapped = serverName
+		return conn, nil
+	}
+	var dialed []string
+	lines := make(chan string, 1)
+	chain := NewChainProxyConnector(time.Second, logging.NewFakeLogger(),
+		WithDialFunc(pipeDialer(t, &dialed, lines)), WithTLSWrapFunc(wrap))
+	conn, err := chain.ConnectChain(context.Background(), []*url.URL{{Scheme: "testwrapped", Host: "tunnel.example:9000"}}, "example.com:443")
+	if err != nil {
+		t.Fatalf("ConnectChain() error = %v", err)
+	}
+	defer conn.Close()
+
+	if wrapped != "tunnel.example" {
+		t.Errorf("TLS握手的服务器名 = %q, want tunnel.example", wrapped)
+	}
+	if line := <-lines; line != "TUNNEL example.com:443\n" {
+		t.Errorf("代理服务器收到 %q", line)
+	}
+}
+
+func TestRegisterNil(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("注册空的连接器应 panic")
+		}
+	}()
+	Register("testnil", nil)
+}
+
+func TestRegisterConnectOnly(t *testing.T) {
+	// 到 tunnel.example 的连接先读取一行 TUNNEL 请求，再作为HTTP代理响应CONNECT请求
+	var dialed []string
+	lines := make(chan string, 1)
+	var gotTarget, gotAuth string
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		conn, _ := pipeProxy(t, func(conn net.Conn) {
+			br := bufio.NewReader(conn)
+			line, _ := br.ReadString('\n')
+			lines <- line
+			serveCONNECT(t, &bufferedConn{Conn: conn, r: br}, http.StatusOK, &gotTarget, &gotAuth)
+		})
+		return conn, nil
+	}
+	registerForTest(t, "testconnect", func() {
+		Register("testconnect", func(timeout time.Duration, logger logging.ILogger) ProxyConnector {
+			return &connectOnlyConnector{dial: dial}
+		})
+	})
+
+	// 链配置的拨号函数不应被使用，第一跳由连接器的 Connect 自行拨号
+	chainDial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Errorf("代理链不应为只实现 Connect 的连接器拨号: %s", addr)
+		return nil, errors.New("unexpected dial")
+	}
+	chain := NewChainProxyConnector(time.Second, logging.NewFakeLogger(), WithDialFunc(chainDial))
+
+	t.Run("第一跳", func(t *testing.T) {
+		conn, err := chain.ConnectChain(context.Background(), []*url.URL{
+			{Scheme: "testconnect", Host: "tunnel.example:9000"},
+			{Scheme: "http", Host: "proxy.example:8080"},
+		}, "example.com:443")
+		if err != nil {
+			t.Fatalf("ConnectChain() error = %v", err)
+		}
+		defer conn.Close()
+		checkTunnel(t, conn)
+
+		if len(dialed) != 1 || dialed[0] != "tunnel.example:9000" {
+			t.Errorf("拨号地址 = %v, want [tunnel.example:9000]", dialed)
+		}
+		if line := <-lines; line != "TUNNEL proxy.example:8080\n" {
+			t.Errorf("代理服务器收到 %q", line)
+		}
+		if gotTarget != "example.com:443" {
+			t.Errorf("第二跳的CONNECT目标 = %q, want example.com:443", gotTarget)
+		}
+	})
+
+	t.Run("后续跳", func(t *testing.T) {
+		registerForTest(t, "testtunnel", func() {
+			Register("testtunnel", func(timeout time.Duration, logger logging.ILogger) ProxyConnector {
+				return &tunnelConnector{}
+			})
+		})
+		var dialed []string
+		lines := make(chan string, 1)
+		chain := NewChainProxyConnector(time.Second, logging.NewFakeLogger(), WithDialFunc(pipeDialer(t, &dialed, lines)))
+		_, err := chain.ConnectChain(context.Background(), []*url.URL{
+			{Scheme: "testtunnel", Host: "first.example:9000"},
+			{Scheme: "testconnect", Host: "tunnel.example:9000"},
+		}, "example.com:443")
+		var hopErr *HopError
+		if !errors.As(err, &hopErr) || hopErr.Index != 1 {
+			t.Fatalf("ConnectChain() error = %v, want 第2跳的 *HopError", err)
+		}
+		if !strings.Contains(err.Error(), "ProxyHandshaker") {
+			t.Errorf("ConnectChain() error = %v, 应说明连接器未实现 ProxyHandshaker", err)
+		}
+	})
+}
+
+// bufferedConn 从 r 读取已缓冲的数据，写入直接发送到 Conn
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/internal/netutil"
 )
 
 const (
@@ -54,40 +55,78 @@ const (
 type Socks5ProxyConnector struct {
 	timeout time.Duration
 	logger  logging.ILogger
+	dial    DialFunc
 }
 
-func NewSocks5ProxyConnector(timeout time.Duration, logger logging.ILogger) ProxyConnector {
+func NewSocks5ProxyConnector(timeout time.Duration, logger logging.ILogger, opts ...ConnectorOption) ProxyConnector {
+	options := NewConnectorOptions(opts...)
+
 	return &Socks5ProxyConnector{
 		timeout: timeout,
 		logger:  logger,
+		dial:    options.dial,
 	}
 }
 
 func (c *Socks5ProxyConnector) Connect(ctx context.Context, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
 	c.logger.Info(fmt.Sprintf("[SOCKS5] 连接到代理服务器 %s", proxyURL.Host))
 
+	// 超时覆盖连接代理服务器和SOCKS5协商两个阶段
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
 	// 连接到代理服务器
-	dialer := &net.Dialer{Timeout: c.timeout}
-	conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+	conn, err := c.dial(ctx, "tcp", proxyURL.Host)
 	if err != nil {
 		c.logger.Error(fmt.Sprintf("连接SOCKS5代理服务器 %s 失败", proxyURL.Host), err)
-		return nil, fmt.Errorf("连接SOCKS5代理服务器 %s 失败: %v", proxyURL.Host, err)
+		return nil, fmt.Errorf("连接SOCKS5代理服务器 %s 失败: %w", proxyURL.Host, err)
 	}
 
-	// 进行握手
-	if err := c.handshake(conn, proxyURL); err != nil {
+	if err := c.negotiate(ctx, conn, proxyURL, targetAddr); err != nil {
 		conn.Close()
 		return nil, err
 	}
+	return conn, nil
+}
+
+// Handshake 在已建立的连接上进行SOCKS5协商，失败时不关闭连接
+func (c *Socks5ProxyConnector) Handshake(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	if err := c.negotiate(ctx, conn, proxyURL, targetAddr); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// negotiate 完成认证并请求连接目标地址
+func (c *Socks5ProxyConnector) negotiate(ctx context.Context, conn net.Conn, proxyURL *url.URL, targetAddr string) error {
+	stop := netutil.WatchContext(ctx, conn)
+
+	// 进行握手
+	if err := c.handshake(conn, proxyURL); err != nil {
+		stop()
+		return netutil.ContextError(ctx, err)
+	}
 
 	// 发送连接请求
 	if err := c.connectTarget(conn, targetAddr); err != nil {
-		conn.Close()
-		return nil, err
+		stop()
+		return netutil.ContextError(ctx, err)
+	}
+
+	if err := stop(); err != nil {
+		return err
 	}
 
 	c.logger.Info(fmt.Sprintf("[SOCKS5] 成功建立到 %s 的连接", targetAddr))
-	return conn, nil
+	return nil
 }
 
 func (c *Socks5ProxyConnector) handshake(conn net.Conn, proxyURL *url.URL) error {
@@ -108,14 +147,14 @@ func (c *Socks5ProxyConnector) handshake(conn net.Conn, proxyURL *url.URL) error
 
 	if _, err := conn.Write(request); err != nil {
 		c.logger.Error("发送SOCKS5握手请求失败", err)
-		return fmt.Errorf("发送SOCKS5握手请求失败: %v", err)
+		return fmt.Errorf("发送SOCKS5握手请求失败: %w", err)
 	}
 
 	// 读取服务器响应
 	response := make([]byte, 2)
 	if _, err := io.ReadFull(conn, response); err != nil {
 		c.logger.Error("读取SOCKS5握手响应失败", err)
-		return fmt.Errorf("读取SOCKS5握手响应失败: %v", err)
+		return fmt.Errorf("读取SOCKS5握手响应失败: %w", err)
 	}
 
 	if response[0] != socks5Version {
@@ -158,14 +197,14 @@ func (c *Socks5ProxyConnector) authenticate(conn net.Conn, proxyURL *url.URL) er
 
 	if _, err := conn.Write(request); err != nil {
 		c.logger.Error("发送认证请求失败", err)
-		return fmt.Errorf("发送认证请求失败: %v", err)
+		return fmt.Errorf("发送认证请求失败: %w", err)
 	}
 
 	// 读取认证响应
 	response := make([]byte, 2)
 	if _, err := io.ReadFull(conn, response); err != nil {
 		c.logger.Error("读取认证响应失败", err)
-		return fmt.Errorf("读取认证响应失败: %v", err)
+		return fmt.Errorf("读取认证响应失败: %w", err)
 	}
 
 	if response[1] != 0x00 {
@@ -220,14 +259,14 @@ func (c *Socks5ProxyConnector) connectTarget(conn net.Conn, targetAddr string) e
 	// 发送请求
 	if _, err := conn.Write(request); err != nil {
 		c.logger.Error("发送连接请求失败", err)
-		return fmt.Errorf("发送连接请求失败: %v", err)
+		return fmt.Errorf("发送连接请求失败: %w", err)
 	}
 
 	// 读取响应
 	response := make([]byte, 4)
 	if _, err := io.ReadFull(conn, response); err != nil {
 		c.logger.Error("读取连接响应失败", err)
-		return fmt.Errorf("读取连接响应失败: %v", err)
+		return fmt.Errorf("读取连接响应失败: %w", err)
 	}
 
 	if response[1] != respSucceeded {
@@ -238,19 +277,19 @@ func (c *Socks5ProxyConnector) connectTarget(conn net.Conn, targetAddr string) e
 	switch response[3] {
 	case addrTypeIPv4:
 		if _, err := io.CopyN(io.Discard, conn, 4+2); err != nil {
-			return fmt.Errorf("读取响应地址失败: %v", err)
+			return fmt.Errorf("读取响应地址失败: %w", err)
 		}
 	case addrTypeIPv6:
 		if _, err := io.CopyN(io.Discard, conn, 16+2); err != nil {
-			return fmt.Errorf("读取响应地址失败: %v", err)
+			return fmt.Errorf("读取响应地址失败: %w", err)
 		}
 	case addrTypeDomain:
 		domainLen := make([]byte, 1)
 		if _, err := io.ReadFull(conn, domainLen); err != nil {
-			return fmt.Errorf("读取响应域名长度失败: %v", err)
+			return fmt.Errorf("读取响应域名长度失败: %w", err)
 		}
 		if _, err := io.CopyN(io.Discard, conn, int64(domainLen[0])+2); err != nil {
-			return fmt.Errorf("读取响应域名失败: %v", err)
+			return fmt.Errorf("读取响应域名失败: %w", err)
 		}
 	}
 
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries 缓存条目数超过该值时清理已过期的条目
+const maxCacheEntries = 4096
+
+type cacheEntry struct {
+	ips     []net.IP
+	err     error // 否定缓存: 记录不存在时的错误
+	expires time.Time
+}
+
+// CachingResolver 缓存解析结果。上游实现 ITTLResolver 时按记录的TTL缓存，否则使用默认TTL；
+// 上游返回记录不存在并给出否定缓存TTL时，在该时间内直接返回同样的错误
+type CachingResolver struct {
+	upstream   IResolver
+	defaultTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCachingResolver 创建带缓存的解析器，defaultTTL 用于不提供TTL的上游 (如系统解析器、hosts)
+func NewCachingResolver(upstream IResolver, defaultTTL time.Duration) IResolver {
+	return &CachingResolver{
+		upstream:   upstream,
+		defaultTTL: defaultTTL,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+func (r *CachingResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	key := network + "|" + strings.ToLower(host)
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		return slices.Clone(entry.ips), nil
+	}
+
+	var (
+		ips []net.IP
+		ttl = r.defaultTTL
+		err error
+	)
+	if ttlResolver, ok := r.upstream.(ITTLResolver); ok {
+		ips, ttl, err = ttlResolver.LookupIPTTL(ctx, network, host)
+	} else {
+		ips, err = r.upstream.LookupIP(ctx, network, host)
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if ttl > 0 && errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			r.store(key, cacheEntry{err: err, expires: time.Now().Add(ttl)})
+		}
+		return nil, err
+	}
+
+	if ttl > 0 {
+		r.store(key, cacheEntry{ips: slices.Clone(ips), expires: time.Now().Add(ttl)})
+	}
+	return ips, nil
+}
+
+// Flush 清空缓存
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = make(map[string]cacheEntry)
+}
+
+func (r *CachingResolver) store(key string, entry cacheEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.entries) >= maxCacheEntries {
+		now := time.Now()
+		for k, e := range r.entries {
+			if now.After(e.expires) {
+				delete(r.entries, k)
+			}
+		}
+	}
+	r.entries[key] = entry
+}
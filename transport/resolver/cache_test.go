@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeTTLResolver 返回固定结果并统计查询次数
+type fakeTTLResolver struct {
+	ips   []net.IP
+	ttl   time.Duration
+	err   error
+	calls atomic.Int32
+}
+
+func (r *fakeTTLResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	ips, _, err := r.LookupIPTTL(ctx, network, host)
+	return ips, err
+}
+
+func (r *fakeTTLResolver) LookupIPTTL(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	r.calls.Add(1)
+	return r.ips, r.ttl, r.err
+}
+
+// plainResolver 不提供TTL的上游
+type plainResolver struct {
+	ips   []net.IP
+	err   error
+	calls atomic.Int32
+}
+
+func (r *plainResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	r.calls.Add(1)
+	return r.ips, r.err
+}
+
+func TestCachingResolverTTL(t *testing.T) {
+	upstream := &fakeTTLResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}, ttl: 200 * time.Millisecond}
+	r := NewCachingResolver(upstream, time.Hour)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := r.LookupIP(ctx, "ip", "www.test"); err != nil {
+			t.Fatalf("LookupIP() error = %v", err)
+		}
+	}
+	if n := upstream.calls.Load(); n != 1 {
+		t.Fatalf("TTL内查询上游 %d 次，want 1", n)
+	}
+
+	// 主机名不区分大小写，不同 network 分别缓存
+	r.LookupIP(ctx, "ip", "WWW.test")
+	if n := upstream.calls.Load(); n != 1 {
+		t.Errorf("大小写不同的主机名未命中缓存，查询上游 %d 次", n)
+	}
+	r.LookupIP(ctx, "ip4", "www.test")
+	if n := upstream.calls.Load(); n != 2 {
+		t.Errorf("不同 network 查询上游 %d 次，want 2", n)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if _, err := r.LookupIP(ctx, "ip", "www.test"); err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if n := upstream.calls.Load(); n != 3 {
+		t.Errorf("TTL过期后查询上游 %d 次，want 3", n)
+	}
+}
+
+func TestCachingResolverZeroTTL(t *testing.T) {
+	upstream := &fakeTTLResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}}
+	r := NewCachingResolver(upstream, time.Hour)
+	r.LookupIP(context.Background(), "ip", "www.test")
+	r.LookupIP(context.Background(), "ip", "www.test")
+	if n := upstream.calls.Load(); n != 2 {
+		t.Errorf("TTL为0的记录被缓存，查询上游 %d 次，want 2", n)
+	}
+}
+
+func TestCachingResolverDefaultTTL(t *testing.T) {
+	upstream := &plainResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}}
+	r := NewCachingResolver(upstream, time.Hour)
+	r.LookupIP(context.Background(), "ip", "www.test")
+	r.LookupIP(context.Background(), "ip", "www.test")
+	if n := upstream.calls.Load(); n != 1 {
+		t.Errorf("不提供TTL的上游未按默认TTL缓存，查询上游 %d 次", n)
+	}
+}
+
+func TestCachingResolverReturnsCopy(t *testing.T) {
+	upstream := &fakeTTLResolver{ips: []net.IP{net.ParseIP("192.0.2.1")}, ttl: time.Hour}
+	r := NewCachingResolver(upstream, time.Hour)
+	ips, _ := r.LookupIP(context.Background(), "ip", "www.test")
+	ips[0] = net.ParseIP("203.0.113.1")
+	if ips, _ := r.LookupIP(context.Background(), "ip", "www.test"); !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+		t.Errorf("修改返回值影响了缓存: %v", ips)
+	}
+}
+
+func TestCachingResolverNegative(t *testing.T) {
+	notFound := &net.DNSError{Err: "域名不存在", Name: "missing.test", IsNotFound: true}
+	tests := []struct {
+		name      string
+		err       error
+		ttl       time.Duration
+		wantCalls int32
+	}{
+		{"记录不存在时按否定TTL缓存", notFound, time.Hour, 1},
+		{"多个错误中的记录不存在", errors.Join(notFound, notFound), time.Hour, 1},
+		{"否定TTL为0时不缓存", notFound, 0, 2},
+		{"临时错误不缓存", &net.DNSError{Err: "SERVFAIL", Name: "missing.test", IsTemporary: true}, time.Hour, 2},
+		{"其他错误不缓存", errors.New("connection refused"), time.Hour, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &fakeTTLResolver{err: tt.err, ttl: tt.ttl}
+			r := NewCachingResolver(upstream, time.Hour)
+			for range 2 {
+				if _, err := r.LookupIP(context.Background(), "ip", "missing.test"); !errors.Is(err, tt.err) {
+					t.Fatalf("LookupIP() error = %v, want %v", err, tt.err)
+				}
+			}
+			if n := upstream.calls.Load(); n != tt.wantCalls {
+				t.Errorf("查询上游 %d 次，want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachingResolverNegativeExpires(t *testing.T) {
+	upstream := &fakeTTLResolver{err: &net.DNSError{Err: "域名不存在", IsNotFound: true}, ttl: 100 * time.Millisecond}
+	r := NewCachingResolver(upstream, time.Hour)
+	r.LookupIP(context.Background(), "ip", "missing.test")
+	time.Sleep(200 * time.Millisecond)
+
+	// 否定缓存过期后重新查询，此时记录已存在
+	upstream.err, upstream.ips, upstream.ttl = nil, []net.IP{net.ParseIP("192.0.2.1")}, time.Hour
+	ips, err := r.LookupIP(context.Background(), "ip", "missing.test")
+	if err != nil || len(ips) != 1 {
+		t.Fatalf("LookupIP() = %v, %v, want 否定缓存过期后返回新记录", ips, err)
+	}
+}
+
+func TestCachingResolverNXDOMAINFromDNS(t *testing.T) {
+	stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+		return reply(t, q, dnsmessage.RCodeNameError, stubRecords{}, 30)
+	})
+	r := NewCachingResolver(newTestDNSResolver(t, stub.addr, "udp"), time.Hour)
+	for range 3 {
+		_, err := r.LookupIP(context.Background(), "ip4", "missing.test")
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Fatalf("LookupIP() error = %v, want IsNotFound", err)
+		}
+	}
+	if n := stub.udpCount.Load(); n != 1 {
+		t.Errorf("NXDOMAIN 未被缓存，DNS服务器收到 %d 次查询", n)
+	}
+}
@@ -0,0 +1,349 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// defaultDNSTimeout 未设置 ctx 截止时间时单次DNS查询的超时时间
+	defaultDNSTimeout = 5 * time.Second
+	// ednsUDPSize 通过EDNS0声明的UDP报文长度，参见 DNS Flag Day 2020
+	ednsUDPSize = 1232
+	// maxDNSMessageSize DNS报文的最大长度
+	maxDNSMessageSize = 65535
+)
+
+// exchangeFunc 发送DNS查询报文并返回响应报文
+type exchangeFunc func(ctx context.Context, query []byte) ([]byte, error)
+
+// dnsClient 基于 exchangeFunc 的A/AAAA查询，UDP/TCP/DoH 共用
+type dnsClient struct {
+	exchange exchangeFunc
+	// zeroID 为 true 时查询ID固定为0，参见 RFC 8484 4.1
+	zeroID bool
+}
+
+func (c *dnsClient) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	ips, _, err := c.LookupIPTTL(ctx, network, host)
+	return ips, err
+}
+
+func (c *dnsClient) LookupIPTTL(ctx context.Context, network, host string) ([]net.IP, time.Duration, error) {
+	var types []dnsmessage.Type
+	switch network {
+	case "ip4":
+		types = []dnsmessage.Type{dnsmessage.TypeA}
+	case "ip6":
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA}
+	default:
+		types = []dnsmessage.Type{dnsmessage.TypeAAAA, dnsmessage.TypeA}
+	}
+
+	type result struct {
+		ips []net.IP
+		ttl time.Duration
+		err error
+	}
+	results := make([]result, len(types))
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ips, ttl, err := c.query(ctx, host, qtype)
+			results[i] = result{ips, ttl, err}
+		}()
+	}
+	wg.Wait()
+
+	var (
+		ips  []net.IP
+		ttl  time.Duration = -1
+		errs []error
+	)
+	// 每个查询都明确返回记录不存在且带有否定缓存TTL时，取其中最小的TTL
+	negative, negTTL := true, time.Duration(-1)
+	for _, res := range results {
+		if len(res.ips) == 0 {
+			var dnsErr *net.DNSError
+			if res.ttl <= 0 || (res.err != nil && !(errors.As(res.err, &dnsErr) && dnsErr.IsNotFound)) {
+				negative = false
+			} else if negTTL < 0 || res.ttl < negTTL {
+				negTTL = res.ttl
+			}
+		}
+		if res.err != nil {
+			errs = append(errs, res.err)
+			continue
+		}
+		ips = append(ips, res.ips...)
+		if len(res.ips) > 0 && (ttl < 0 || res.ttl < ttl) {
+			ttl = res.ttl
+		}
+	}
+	if len(ips) == 0 {
+		if !negative {
+			negTTL = 0
+		}
+		if len(errs) > 0 {
+			return nil, negTTL, errors.Join(errs...)
+		}
+		return nil, negTTL, &net.DNSError{Err: "没有找到记录", Name: host, IsNotFound: true}
+	}
+	return ips, ttl, nil
+}
+
+// query 查询单个记录类型，返回地址和其中最小的TTL
+func (c *dnsClient) query(ctx context.Context, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	fqdn := host
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: "无效的主机名", Name: host}
+	}
+
+	var id uint16
+	if !c.zeroID {
+		id = uint16(rand.Uint32())
+	}
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	builder.StartQuestions()
+	builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET})
+	builder.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(ednsUDPSize, dnsmessage.RCodeSuccess, false)
+	builder.OPTResource(opt, dnsmessage.OPTResource{})
+	query, err := builder.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := c.exchange(ctx, query)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host, IsTimeout: errors.Is(err, context.DeadlineExceeded)}
+	}
+	return parseAnswer(resp, id, host, qtype)
+}
+
+// parseAnswer 解析响应中的A/AAAA记录。域名不存在或没有该类型的记录时，
+// 返回的TTL取自授权部分的SOA记录 (RFC 2308)，没有SOA记录时为0
+func parseAnswer(resp []byte, id uint16, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的DNS响应: %v", err), Name: host}
+	}
+	if header.ID != id || !header.Response {
+		return nil, 0, &net.DNSError{Err: "DNS响应与查询不匹配", Name: host}
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		var ttl time.Duration
+		if p.SkipAllQuestions() == nil && p.SkipAllAnswers() == nil {
+			ttl = negativeTTL(&p)
+		}
+		return nil, ttl, &net.DNSError{Err: "域名不存在", Name: host, IsNotFound: true}
+	default:
+		return nil, 0, &net.DNSError{Err: fmt.Sprintf("DNS服务器返回错误: %s", header.RCode), Name: host, IsTemporary: header.RCode == dnsmessage.RCodeServerFailure}
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的DNS响应: %v", err), Name: host}
+	}
+
+	var (
+		ips []net.IP
+		ttl time.Duration
+	)
+	for {
+		h, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的DNS响应: %v", err), Name: host}
+		}
+
+		var ip net.IP
+		switch {
+		case h.Type == dnsmessage.TypeA && qtype == dnsmessage.TypeA:
+			r, err := p.AResource()
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的A记录: %v", err), Name: host}
+			}
+			ip = net.IP(r.A[:])
+		case h.Type == dnsmessage.TypeAAAA && qtype == dnsmessage.TypeAAAA:
+			r, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的AAAA记录: %v", err), Name: host}
+			}
+			ip = net.IP(r.AAAA[:])
+		default:
+			// CNAME 等其他记录
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, &net.DNSError{Err: fmt.Sprintf("无效的DNS响应: %v", err), Name: host}
+			}
+			continue
+		}
+
+		recordTTL := time.Duration(h.TTL) * time.Second
+		if len(ips) == 0 || recordTTL < ttl {
+			ttl = recordTTL
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) == 0 {
+		ttl = negativeTTL(&p)
+	}
+	return ips, ttl, nil
+}
+
+// negativeTTL 从授权部分的SOA记录计算否定缓存的TTL: min(SOA记录的TTL, SOA.MINIMUM)，
+// 解析器需位于授权部分的开头
+func negativeTTL(p *dnsmessage.Parser) time.Duration {
+	for {
+		h, err := p.AuthorityHeader()
+		if err != nil {
+			return 0
+		}
+		if h.Type != dnsmessage.TypeSOA {
+			if err := p.SkipAuthority(); err != nil {
+				return 0
+			}
+			continue
+		}
+		soa, err := p.SOAResource()
+		if err != nil {
+			return 0
+		}
+		return time.Duration(min(h.TTL, soa.MinTTL)) * time.Second
+	}
+}
+
+// DNSResolver 通过指定的DNS服务器 (UDP或TCP) 解析，UDP响应被截断时自动改用TCP重试
+type DNSResolver struct {
+	dnsClient
+	server  string
+	network string
+}
+
+// NewDNSResolver 创建使用指定DNS服务器的解析器，server 如 "8.8.8.8:53" (省略端口时使用53)，
+// network 为 "udp" 或 "tcp"
+func NewDNSResolver(server, network string) (ITTLResolver, error) {
+	switch network {
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("不支持的DNS传输协议: %s", network)
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	r := &DNSResolver{
+		server:  server,
+		network: network,
+	}
+	if network == "udp" {
+		r.exchange = r.exchangeUDP
+	} else {
+		r.exchange = r.exchangeTCP
+	}
+	return r, nil
+}
+
+func (r *DNSResolver) dial(ctx context.Context, network string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, r.server)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultDNSTimeout)
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+func (r *DNSResolver) exchangeUDP(ctx context.Context, query []byte) ([]byte, error) {
+	conn, err := r.dial(ctx, "udp")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxDNSMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 忽略ID不匹配的报文，避免被伪造的响应干扰
+		if n < 12 || binary.BigEndian.Uint16(buf) != binary.BigEndian.Uint16(query) {
+			continue
+		}
+		// TC 标志位: 响应被截断
+		if buf[2]&0x02 != 0 {
+			return r.exchangeTCP(ctx, query)
+		}
+		return buf[:n], nil
+	}
+}
+
+func (r *DNSResolver) exchangeTCP(ctx context.Context, query []byte) ([]byte, error) {
+	conn, err := r.dial(ctx, "tcp")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
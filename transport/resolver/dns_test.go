@@ -0,0 +1,401 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsHandler 根据查询生成原始响应报文，tcp 表示查询来自TCP连接；返回 nil 时不响应
+type dnsHandler func(query dnsmessage.Message, tcp bool) []byte
+
+// dnsStub 同一端口上同时监听 UDP 和 TCP 的本地DNS服务器
+type dnsStub struct {
+	addr               string
+	udpCount, tcpCount atomic.Int32
+}
+
+func newDNSStub(t *testing.T, handler dnsHandler) *dnsStub {
+	t.Helper()
+	var (
+		pc net.PacketConn
+		ln net.Listener
+	)
+	// UDP 与 TCP 需要使用同一个端口，端口被占用时重试
+	for range 10 {
+		var err error
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatal(err)
+		}
+		if ln, err = net.Listen("tcp", pc.LocalAddr().String()); err == nil {
+			break
+		}
+		pc.Close()
+		pc = nil
+	}
+	if pc == nil {
+		t.Fatal("无法在同一端口上监听 UDP 和 TCP")
+	}
+	t.Cleanup(func() {
+		pc.Close()
+		ln.Close()
+	})
+
+	s := &dnsStub{addr: pc.LocalAddr().String()}
+	go func() {
+		buf := make([]byte, maxDNSMessageSize)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			s.udpCount.Add(1)
+			var query dnsmessage.Message
+			if query.Unpack(buf[:n]) != nil {
+				continue
+			}
+			if resp := handler(query, false); resp != nil {
+				pc.WriteTo(resp, addr)
+			}
+		}
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var length [2]byte
+				if _, err := io.ReadFull(conn, length[:]); err != nil {
+					return
+				}
+				msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+				if _, err := io.ReadFull(conn, msg); err != nil {
+					return
+				}
+				s.tcpCount.Add(1)
+				var query dnsmessage.Message
+				if query.Unpack(msg) != nil {
+					return
+				}
+				resp := handler(query, true)
+				if resp == nil {
+					return
+				}
+				out := make([]byte, 2+len(resp))
+				binary.BigEndian.PutUint16(out, uint16(len(resp)))
+				copy(out[2:], resp)
+				conn.Write(out)
+			}()
+		}
+	}()
+	return s
+}
+
+// stubRecords 按查询类型返回的地址
+type stubRecords struct {
+	ttl  uint32
+	ipv4 []string
+	ipv6 []string
+}
+
+// reply 构造对 query 的响应，rcode 非成功时不携带记录；soaTTL 非0时在授权部分附加SOA记录。
+// 在服务器协程中调用，失败时只报告错误
+func reply(t *testing.T, query dnsmessage.Message, rcode dnsmessage.RCode, records stubRecords, soaTTL uint32) []byte {
+	t.Helper()
+	q := query.Questions[0]
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: query.ID, Response: true, RCode: rcode, RecursionAvailable: true})
+	b.StartQuestions()
+	b.Question(q)
+	b.StartAnswers()
+	if rcode == dnsmessage.RCodeSuccess {
+		hdr := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: records.ttl}
+		switch q.Type {
+		case dnsmessage.TypeA:
+			for _, s := range records.ipv4 {
+				var a dnsmessage.AResource
+				copy(a.A[:], net.ParseIP(s).To4())
+				b.AResource(hdr, a)
+			}
+		case dnsmessage.TypeAAAA:
+			for _, s := range records.ipv6 {
+				var aaaa dnsmessage.AAAAResource
+				copy(aaaa.AAAA[:], net.ParseIP(s))
+				b.AAAAResource(hdr, aaaa)
+			}
+		}
+	}
+	b.StartAuthorities()
+	if soaTTL > 0 {
+		zone := dnsmessage.MustNewName("test.")
+		b.SOAResource(dnsmessage.ResourceHeader{Name: zone, Class: dnsmessage.ClassINET, TTL: soaTTL}, dnsmessage.SOAResource{
+			NS:     dnsmessage.MustNewName("ns.test."),
+			MBox:   dnsmessage.MustNewName("admin.test."),
+			Serial: 1, Refresh: 3600, Retry: 600, Expire: 86400,
+			MinTTL: 60,
+		})
+	}
+	resp, err := b.Finish()
+	if err != nil {
+		t.Errorf("构造DNS响应失败: %v", err)
+	}
+	return resp
+}
+
+func newTestDNSResolver(t *testing.T, server, network string) ITTLResolver {
+	t.Helper()
+	r, err := NewDNSResolver(server, network)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func sortedStrings(ips []net.IP) []string {
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestDNSResolverLookup(t *testing.T) {
+	records := stubRecords{ttl: 300, ipv4: []string{"192.0.2.1", "192.0.2.2"}, ipv6: []string{"2001:db8::1"}}
+	stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+		return reply(t, q, dnsmessage.RCodeSuccess, records, 0)
+	})
+
+	tests := []struct {
+		transport string
+		network   string
+		want      []string
+	}{
+		{"udp", "ip", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}},
+		{"udp", "ip4", []string{"192.0.2.1", "192.0.2.2"}},
+		{"udp", "ip6", []string{"2001:db8::1"}},
+		{"tcp", "ip", []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transport+"/"+tt.network, func(t *testing.T) {
+			r := newTestDNSResolver(t, stub.addr, tt.transport)
+			ips, ttl, err := r.LookupIPTTL(context.Background(), tt.network, "www.test")
+			if err != nil {
+				t.Fatalf("LookupIPTTL() error = %v", err)
+			}
+			if got := sortedStrings(ips); !slices.Equal(got, tt.want) {
+				t.Errorf("LookupIPTTL() = %v, want %v", got, tt.want)
+			}
+			if ttl != 300*time.Second {
+				t.Errorf("TTL = %v, want 5m0s", ttl)
+			}
+		})
+	}
+}
+
+func TestDNSResolverMinTTL(t *testing.T) {
+	stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+		ttl := uint32(300)
+		if q.Questions[0].Type == dnsmessage.TypeAAAA {
+			ttl = 30
+		}
+		return reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: ttl, ipv4: []string{"192.0.2.1"}, ipv6: []string{"2001:db8::1"}}, 0)
+	})
+	_, ttl, err := newTestDNSResolver(t, stub.addr, "udp").LookupIPTTL(context.Background(), "ip", "www.test")
+	if err != nil {
+		t.Fatalf("LookupIPTTL() error = %v", err)
+	}
+	if ttl != 30*time.Second {
+		t.Errorf("TTL = %v, want 30s", ttl)
+	}
+}
+
+func TestDNSResolverTruncatedUsesTCP(t *testing.T) {
+	stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+		if !tcp {
+			// UDP 只返回带 TC 标志的空响应
+			b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: q.ID, Response: true, Truncated: true})
+			b.StartQuestions()
+			b.Question(q.Questions[0])
+			resp, _ := b.Finish()
+			return resp
+		}
+		return reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.9"}}, 0)
+	})
+
+	ips, err := newTestDNSResolver(t, stub.addr, "udp").LookupIP(context.Background(), "ip4", "big.test")
+	if err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if got := sortedStrings(ips); !slices.Equal(got, []string{"192.0.2.9"}) {
+		t.Errorf("LookupIP() = %v, want [192.0.2.9]", got)
+	}
+	if stub.udpCount.Load() != 1 || stub.tcpCount.Load() != 1 {
+		t.Errorf("UDP查询 %d 次、TCP查询 %d 次，want 各1次", stub.udpCount.Load(), stub.tcpCount.Load())
+	}
+}
+
+func TestDNSResolverIgnoresMismatchedUDPResponse(t *testing.T) {
+	// 先发送ID不匹配的伪造响应，再发送正确的响应
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, maxDNSMessageSize)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var q dnsmessage.Message
+		if q.Unpack(buf[:n]) != nil {
+			return
+		}
+		forged := q
+		forged.ID++
+		conn.WriteTo(reply(t, forged, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"203.0.113.66"}}, 0), addr)
+		conn.WriteTo(reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.1"}}, 0), addr)
+	}()
+
+	ips, err := newTestDNSResolver(t, conn.LocalAddr().String(), "udp").LookupIP(context.Background(), "ip4", "www.test")
+	if err != nil {
+		t.Fatalf("LookupIP() error = %v", err)
+	}
+	if got := sortedStrings(ips); !slices.Equal(got, []string{"192.0.2.1"}) {
+		t.Errorf("LookupIP() = %v, want [192.0.2.1]", got)
+	}
+}
+
+func TestDNSResolverNotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		rcode   dnsmessage.RCode
+		soaTTL  uint32
+		wantTTL time.Duration
+	}{
+		// 否定缓存TTL取 min(SOA记录TTL, SOA.MINIMUM=60)
+		{"NXDOMAIN", dnsmessage.RCodeNameError, 30, 30 * time.Second},
+		{"NXDOMAIN取SOA最小值", dnsmessage.RCodeNameError, 3600, 60 * time.Second},
+		{"NXDOMAIN无SOA", dnsmessage.RCodeNameError, 0, 0},
+		{"没有该类型的记录", dnsmessage.RCodeSuccess, 30, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+				return reply(t, q, tt.rcode, stubRecords{}, tt.soaTTL)
+			})
+			ips, ttl, err := newTestDNSResolver(t, stub.addr, "udp").LookupIPTTL(context.Background(), "ip", "missing.test")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+				t.Fatalf("LookupIPTTL() error = %v, want IsNotFound", err)
+			}
+			if len(ips) != 0 {
+				t.Errorf("LookupIPTTL() = %v, want 空", ips)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("否定缓存TTL = %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestDNSResolverMalformedResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		respond       func(t *testing.T, q dnsmessage.Message) []byte
+		wantTemporary bool
+	}{
+		{"无法解析的报文", func(t *testing.T, q dnsmessage.Message) []byte {
+			return []byte{byte(q.ID >> 8), byte(q.ID), 0x81}
+		}, false},
+		{"ID不匹配", func(t *testing.T, q dnsmessage.Message) []byte {
+			q.ID++
+			return reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.1"}}, 0)
+		}, false},
+		{"不是响应报文", func(t *testing.T, q dnsmessage.Message) []byte {
+			resp := reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.1"}}, 0)
+			resp[2] &^= 0x80 // 清除 QR 标志
+			return resp
+		}, false},
+		{"记录被截断", func(t *testing.T, q dnsmessage.Message) []byte {
+			resp := reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.1"}}, 0)
+			return resp[:len(resp)-2]
+		}, false},
+		{"SERVFAIL", func(t *testing.T, q dnsmessage.Message) []byte {
+			return reply(t, q, dnsmessage.RCodeServerFailure, stubRecords{}, 0)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+				return tt.respond(t, q)
+			})
+			ips, ttl, err := newTestDNSResolver(t, stub.addr, "tcp").LookupIPTTL(context.Background(), "ip4", "www.test")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) {
+				t.Fatalf("LookupIPTTL() = %v, %v, want *net.DNSError", ips, err)
+			}
+			if dnsErr.IsNotFound || ttl != 0 {
+				t.Errorf("格式错误的响应不应作为记录不存在缓存: IsNotFound=%v, TTL=%v", dnsErr.IsNotFound, ttl)
+			}
+			if dnsErr.IsTemporary != tt.wantTemporary {
+				t.Errorf("IsTemporary = %v, want %v", dnsErr.IsTemporary, tt.wantTemporary)
+			}
+		})
+	}
+}
+
+func TestDNSResolverTimeout(t *testing.T) {
+	stub := newDNSStub(t, func(q dnsmessage.Message, tcp bool) []byte {
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := newTestDNSResolver(t, stub.addr, "udp").LookupIP(ctx, "ip4", "www.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+		t.Fatalf("LookupIP() error = %v, want 超时错误", err)
+	}
+}
+
+func TestNewDNSResolver(t *testing.T) {
+	if _, err := NewDNSResolver("127.0.0.1", "tls"); err == nil {
+		t.Error("NewDNSResolver() 不支持的传输协议应返回错误")
+	}
+	r, err := NewDNSResolver("192.0.2.53", "udp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.(*DNSResolver).server; got != "192.0.2.53:53" {
+		t.Errorf("省略端口时 server = %s, want 192.0.2.53:53", got)
+	}
+}
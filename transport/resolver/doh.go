@@ -0,0 +1,72 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const dnsMessageContentType = "application/dns-message"
+
+// DoHResolver 通过 DNS-over-HTTPS (RFC 8484) 解析
+type DoHResolver struct {
+	dnsClient
+	endpoint string
+	rt       http.RoundTripper
+}
+
+// NewDoHResolver 创建 DoH 解析器，endpoint 如 "https://1.1.1.1/dns-query"。
+// rt 为空时使用 http.DefaultTransport；传入 transport.NewFingerHttpsTransport(dialer) 即可通过指纹连接发送查询，
+// 查询之间复用同一个连接。
+// 注意 dialer 自身不能使用该 DoH 解析器解析 endpoint 的主机名，可以使用IP地址或 HostsResolver 避免循环依赖
+func NewDoHResolver(endpoint string, rt http.RoundTripper) ITTLResolver {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	r := &DoHResolver{
+		endpoint: endpoint,
+		rt:       rt,
+	}
+	r.exchange = r.exchangeHTTPS
+	r.zeroID = true
+	return r
+}
+
+func (r *DoHResolver) exchangeHTTPS(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageContentType)
+	req.Header.Set("Accept", dnsMessageContentType)
+
+	resp, err := r.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH服务器返回非200状态: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxDNSMessageSize))
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDoHResolver(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != dnsMessageContentType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var q dnsmessage.Message
+		if q.Unpack(body) != nil || q.ID != 0 {
+			// RFC 8484 要求查询ID为0以便缓存
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		w.Write(reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 120, ipv4: []string{"192.0.2.1"}, ipv6: []string{"2001:db8::1"}}, 0))
+	}))
+	defer server.Close()
+
+	r := NewDoHResolver(server.URL+"/dns-query", server.Client().Transport)
+	ips, ttl, err := r.LookupIPTTL(context.Background(), "ip", "www.test")
+	if err != nil {
+		t.Fatalf("LookupIPTTL() error = %v", err)
+	}
+	if got := sortedStrings(ips); !slices.Equal(got, []string{"192.0.2.1", "2001:db8::1"}) {
+		t.Errorf("LookupIPTTL() = %v", got)
+	}
+	if ttl != 120*time.Second {
+		t.Errorf("TTL = %v, want 2m0s", ttl)
+	}
+}
+
+func TestDoHResolverErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"非200状态", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}},
+		{"格式错误的响应", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>not dns</html>"))
+		}},
+		{"ID不为0的响应", func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var q dnsmessage.Message
+			q.Unpack(body)
+			resp := reply(t, q, dnsmessage.RCodeSuccess, stubRecords{ttl: 60, ipv4: []string{"192.0.2.1"}}, 0)
+			binary.BigEndian.PutUint16(resp, 0x1234)
+			w.Write(resp)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(tt.handler)
+			defer server.Close()
+
+			_, err := NewDoHResolver(server.URL, server.Client().Transport).LookupIP(context.Background(), "ip4", "www.test")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || dnsErr.IsNotFound {
+				t.Errorf("LookupIP() error = %v, want 非IsNotFound的 *net.DNSError", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HostsResolver 使用静态的 主机名->IP 映射解析，未命中时交给 fallback 解析
+type HostsResolver struct {
+	hosts    map[string][]net.IP
+	fallback IResolver
+}
+
+// NewHostsResolver 创建静态映射解析器，fallback 为空时未命中的主机名返回错误
+func NewHostsResolver(hosts map[string][]net.IP, fallback IResolver) IResolver {
+	normalized := make(map[string][]net.IP, len(hosts))
+	for host, ips := range hosts {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		normalized[host] = append(normalized[host], ips...)
+	}
+	return &HostsResolver{
+		hosts:    normalized,
+		fallback: fallback,
+	}
+}
+
+func (r *HostsResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if ips, ok := r.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		if ips = filterIPs(ips, network); len(ips) > 0 {
+			return ips, nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback.LookupIP(ctx, network, host)
+	}
+	return nil, &net.DNSError{Err: fmt.Sprintf("hosts 中没有 %s 的 %s 记录", host, network), Name: host, IsNotFound: true}
+}
+
+// filterIPs 按 network 过滤地址族
+func filterIPs(ips []net.IP, network string) []net.IP {
+	var filtered []net.IP
+	for _, ip := range ips {
+		switch {
+		case network == "ip4" && ip.To4() == nil:
+		case network == "ip6" && ip.To4() != nil:
+		default:
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered
+}
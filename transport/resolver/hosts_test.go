@@ -0,0 +1,71 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestHostsResolver(t *testing.T) {
+	fallback := &fakeTTLResolver{ips: []net.IP{net.ParseIP("198.51.100.1")}}
+	r := NewHostsResolver(map[string][]net.IP{
+		"www.test.":   {net.ParseIP("192.0.2.1")},
+		"WWW.TEST":    {net.ParseIP("2001:db8::1")},
+		"v4only.test": {net.ParseIP("192.0.2.2")},
+	}, fallback)
+
+	tests := []struct {
+		name    string
+		network string
+		host    string
+		want    []string
+	}{
+		{"命中hosts优先于回退解析器", "ip", "www.test", []string{"192.0.2.1", "2001:db8::1"}},
+		{"大小写与末尾的点", "ip", "Www.Test.", []string{"192.0.2.1", "2001:db8::1"}},
+		{"按地址族过滤", "ip6", "www.test", []string{"2001:db8::1"}},
+		{"地址族不匹配时回退", "ip6", "v4only.test", []string{"198.51.100.1"}},
+		{"未命中时回退", "ip", "other.test", []string{"198.51.100.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, err := r.LookupIP(context.Background(), tt.network, tt.host)
+			if err != nil {
+				t.Fatalf("LookupIP() error = %v", err)
+			}
+			if got := sortedStrings(ips); !slices.Equal(got, tt.want) {
+				t.Errorf("LookupIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if n := fallback.calls.Load(); n != 2 {
+		t.Errorf("回退解析器被调用 %d 次，want 2", n)
+	}
+}
+
+func TestHostsResolverWithoutFallback(t *testing.T) {
+	r := NewHostsResolver(map[string][]net.IP{"www.test": {net.ParseIP("192.0.2.1")}}, nil)
+	_, err := r.LookupIP(context.Background(), "ip6", "www.test")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("LookupIP() error = %v, want IsNotFound", err)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package resolver
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// IResolver 域名解析器
+type IResolver interface {
+	// LookupIP 解析主机名，network 为 "ip"、"ip4" 或 "ip6"
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// ITTLResolver 能够返回记录TTL的解析器，CachingResolver 据此决定缓存时间
+type ITTLResolver interface {
+	IResolver
+	// LookupIPTTL 解析主机名并返回所有记录中最小的TTL。
+	// 记录不存在时返回 IsNotFound 的 *net.DNSError，TTL 为否定缓存的时间 (RFC 2308)，为0表示不缓存
+	LookupIPTTL(ctx context.Context, network, host string) ([]net.IP, time.Duration, error)
+}
+
+// SystemResolver 使用系统解析器 (net.DefaultResolver)
+type SystemResolver struct{}
+
+func NewSystemResolver() IResolver {
+	return &SystemResolver{}
+}
+
+func (r *SystemResolver) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, network, host)
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+type alpnKey struct{}
+
+// ContextWithALPN 为单次拨号强制使用指定的ALPN协议列表，优先于 WithALPN，
+// 例如只支持 HTTP/1.1 的客户端可以传入 "http/1.1"
+func ContextWithALPN(ctx context.Context, protos ...string) context.Context {
+	return context.WithValue(ctx, alpnKey{}, slices.Clone(protos))
+}
+
+// applyALPN 以指纹中的 ALPN 扩展为准确定本次握手的ALPN协议列表。
+// 强制指定ALPN时改写指纹中的 ALPN 扩展，并移除 application_settings 中不再协商的协议
+func (d *BaseTLSDialer) applyALPN(ctx context.Context, spec *utls.ClientHelloSpec) []string {
+	forced := d.opts.alpn
+	if protos, ok := ctx.Value(alpnKey{}).([]string); ok {
+		forced = protos
+	}
+
+	var alpn *utls.ALPNExtension
+	for _, ext := range spec.Extensions {
+		if e, ok := ext.(*utls.ALPNExtension); ok {
+			alpn = e
+			break
+		}
+	}
+
+	if forced == nil {
+		if alpn == nil {
+			return nil
+		}
+		return slices.Clone(alpn.AlpnProtocols)
+	}
+
+	if alpn == nil {
+		d.opts.logger.Warn(fmt.Sprintf("[TLS] 指纹中没有 ALPN 扩展，忽略强制指定的ALPN %v", forced))
+		return nil
+	}
+	if !slices.Equal(alpn.AlpnProtocols, forced) {
+		d.opts.logger.Warn(fmt.Sprintf("[TLS] 强制指定的ALPN %v 与指纹中的ALPN %v 不一致，以强制指定的为准", forced, alpn.AlpnProtocols))
+	}
+	rewriteALPN(spec, alpn, forced)
+	return slices.Clone(forced)
+}
+
+// rewriteALPN 将 ALPN 扩展改写为 protos，ALPS (新旧编号) 只能携带参与ALPN协商的协议，
+// 没有剩余协议的ALPS扩展被移除
+func rewriteALPN(spec *utls.ClientHelloSpec, alpn *utls.ALPNExtension, protos []string) {
+	alpn.AlpnProtocols = slices.Clone(protos)
+	spec.Extensions = slices.DeleteFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+		var supported *[]string
+		switch alps := ext.(type) {
+		case *utls.ApplicationSettingsExtension:
+			supported = &alps.SupportedProtocols
+		case *utls.ApplicationSettingsExtensionNew:
+			supported = &alps.SupportedProtocols
+		default:
+			return false
+		}
+		*supported = slices.DeleteFunc(slices.Clone(*supported), func(p string) bool {
+			return !slices.Contains(protos, p)
+		})
+		return len(*supported) == 0
+	})
+}
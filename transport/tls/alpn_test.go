@@ -0,0 +1,248 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"slices"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
+)
+
+// chromeWithBothALPS Chrome 124 同时携带新旧两个编号的 ALPS 扩展，新编号的扩展包含两个协议
+func chromeWithBothALPS() *utls.ClientHelloSpec {
+	spec := fingerprint.GetChrome124ClientHelloSpec()
+	i := slices.IndexFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+		_, ok := ext.(*utls.ApplicationSettingsExtension)
+		return ok
+	})
+	spec.Extensions = slices.Insert(spec.Extensions, i+1, utls.TLSExtension(
+		&utls.ApplicationSettingsExtensionNew{SupportedProtocols: []string{"h2", "http/1.1"}},
+	))
+	return spec
+}
+
+// alpsProtocols 返回规范中新旧两个 ALPS 扩展的协议列表，扩展不存在时为空
+func alpsProtocols(spec *utls.ClientHelloSpec) (oldALPS, newALPS []string) {
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.ApplicationSettingsExtension:
+			oldALPS = e.SupportedProtocols
+		case *utls.ApplicationSettingsExtensionNew:
+			newALPS = e.SupportedProtocols
+		}
+	}
+	return oldALPS, newALPS
+}
+
+func alpnProtocols(spec *utls.ClientHelloSpec) []string {
+	for _, ext := range spec.Extensions {
+		if e, ok := ext.(*utls.ALPNExtension); ok {
+			return e.AlpnProtocols
+		}
+	}
+	return nil
+}
+
+func TestApplyALPN(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     func() *utls.ClientHelloSpec
+		opts     []Option
+		ctx      []string // 单次拨号指定的ALPN，为空时不设置
+		want     []string
+		wantALPN []string
+		wantOld  []string
+		wantNew  []string
+	}{
+		{
+			name:     "未指定时使用指纹中的ALPN",
+			spec:     chromeWithBothALPS,
+			want:     []string{"h2", "http/1.1"},
+			wantALPN: []string{"h2", "http/1.1"},
+			wantOld:  []string{"h2"},
+			wantNew:  []string{"h2", "http/1.1"},
+		},
+		{
+			name:     "只保留h2",
+			spec:     chromeWithBothALPS,
+			opts:     []Option{WithALPN("h2")},
+			want:     []string{"h2"},
+			wantALPN: []string{"h2"},
+			wantOld:  []string{"h2"},
+			wantNew:  []string{"h2"},
+		},
+		{
+			name:     "只保留http/1.1时移除旧ALPS",
+			spec:     chromeWithBothALPS,
+			opts:     []Option{WithALPN("http/1.1")},
+			want:     []string{"http/1.1"},
+			wantALPN: []string{"http/1.1"},
+			wantNew:  []string{"http/1.1"},
+		},
+		{
+			name:     "单次拨号优先于拨号器配置",
+			spec:     chromeWithBothALPS,
+			opts:     []Option{WithALPN("h2")},
+			ctx:      []string{"http/1.1"},
+			want:     []string{"http/1.1"},
+			wantALPN: []string{"http/1.1"},
+			wantNew:  []string{"http/1.1"},
+		},
+		{
+			name:     "不再协商的协议全部移除",
+			spec:     chromeWithBothALPS,
+			ctx:      []string{"h3"},
+			want:     []string{"h3"},
+			wantALPN: []string{"h3"},
+		},
+		{
+			name: "指纹没有ALPN扩展时忽略",
+			spec: func() *utls.ClientHelloSpec {
+				spec := chromeWithBothALPS()
+				spec.Extensions = slices.DeleteFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+					_, ok := ext.(*utls.ALPNExtension)
+					return ok
+				})
+				return spec
+			},
+			opts:    []Option{WithALPN("http/1.1")},
+			wantOld: []string{"h2"},
+			wantNew: []string{"h2", "http/1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewTLSDialer(append([]Option{WithLogger(logging.NewFakeLogger())}, tt.opts...)...).(*BaseTLSDialer)
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = ContextWithALPN(ctx, tt.ctx...)
+			}
+			spec := tt.spec()
+			if got := d.applyALPN(ctx, spec); !slices.Equal(got, tt.want) {
+				t.Errorf("applyALPN() = %v, want %v", got, tt.want)
+			}
+			if got := alpnProtocols(spec); !slices.Equal(got, tt.wantALPN) {
+				t.Errorf("ALPN扩展 = %v, want %v", got, tt.wantALPN)
+			}
+			oldALPS, newALPS := alpsProtocols(spec)
+			if !slices.Equal(oldALPS, tt.wantOld) {
+				t.Errorf("application_settings = %v, want %v", oldALPS, tt.wantOld)
+			}
+			if !slices.Equal(newALPS, tt.wantNew) {
+				t.Errorf("application_settings_new = %v, want %v", newALPS, tt.wantNew)
+			}
+		})
+	}
+}
+
+func TestDialALPN(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "origin.example", ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	const (
+		extALPS    = 17513
+		extALPSNew = 17613
+	)
+	tests := []struct {
+		name        string
+		ctx         []string
+		want        string
+		wantALPN    []string
+		wantALPSNew []string
+		wantExts    []uint16
+		withoutExts []uint16
+	}{
+		{
+			name:        "使用指纹中的ALPN",
+			want:        "h2",
+			wantALPN:    []string{"h2", "http/1.1"},
+			wantALPSNew: []string{"h2", "http/1.1"},
+			wantExts:    []uint16{extALPS, extALPSNew},
+		},
+		{
+			name:        "强制http/1.1",
+			ctx:         []string{"http/1.1"},
+			want:        "http/1.1",
+			wantALPN:    []string{"http/1.1"},
+			wantALPSNew: []string{"http/1.1"},
+			wantExts:    []uint16{extALPSNew},
+			withoutExts: []uint16{extALPS},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, hellos := serveHello(t, leaf, func(config *tls.Config) {
+				config.NextProtos = []string{"h2", "http/1.1"}
+			})
+			dialer, err := NewTLSDialerE(
+				WithLogger(logging.NewFakeLogger()),
+				WithRootCAs(roots),
+				WithServerName("origin.example"),
+				WithSpecFactory(chromeWithBothALPS),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = ContextWithALPN(ctx, tt.ctx...)
+			}
+
+			conn, err := dialer.DialTLS(ctx, "tcp", addr)
+			if err != nil {
+				t.Fatalf("DialTLS() error = %v", err)
+			}
+			defer conn.Close()
+
+			if got := conn.NegotiatedProtocol(); got != tt.want {
+				t.Errorf("NegotiatedProtocol() = %q, want %q", got, tt.want)
+			}
+			hello := <-hellos
+			if !slices.Equal(hello.ALPN, tt.wantALPN) {
+				t.Errorf("服务器收到的ALPN = %v, want %v", hello.ALPN, tt.wantALPN)
+			}
+			for _, id := range tt.wantExts {
+				if !slices.Contains(hello.Extensions, id) {
+					t.Errorf("ClientHello 缺少扩展 %d", id)
+				}
+			}
+			for _, id := range tt.withoutExts {
+				if slices.Contains(hello.Extensions, id) {
+					t.Errorf("ClientHello 不应包含扩展 %d", id)
+				}
+			}
+
+			// 从实际发送的报文中解析新ALPS扩展携带的协议
+			sent, err := fingerprint.FromClientHelloBytes(conn.ClientHello())
+			if err != nil {
+				t.Fatalf("FromClientHelloBytes() error = %v", err)
+			}
+			if _, got := alpsProtocols(sent()); !slices.Equal(got, tt.wantALPSNew) {
+				t.Errorf("发送的 application_settings_new = %v, want %v", got, tt.wantALPSNew)
+			}
+		})
+	}
+}
@@ -19,9 +19,13 @@ package tls
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"strings"
 
+	"github.com/aberstone/fingertls/transport/internal/netutil"
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
 	utls "github.com/refraction-networking/utls"
 )
 
@@ -34,15 +38,47 @@ func extractServerName(addr string) string {
 	return host
 }
 
-func (d *BaseTLSDialer) handshakeTLS(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
-	d.opts.logger.Info(fmt.Sprintf("[TLS] 开始与 %s 进行TLS握手", serverName))
+// specFactory 返回本次连接使用的指纹，配置了指纹池时从池中选择
+func (d *BaseTLSDialer) specFactory(serverName string) fingerprint.SpecFactory {
+	if d.opts.pool == nil {
+		return d.opts.sf
+	}
+	entry := d.opts.pool.Select(serverName)
+	d.opts.logger.Debug(fmt.Sprintf("[TLS] 从指纹池中选择指纹: %s", entry.Name))
+	return entry.Spec
+}
+
+// clientCertificates 返回本次连接使用的客户端证书，优先使用为该主机单独配置的证书
+func (d *BaseTLSDialer) clientCertificates(serverName string) []utls.Certificate {
+	if certs, ok := d.opts.hostClientCerts[strings.ToLower(serverName)]; ok {
+		return certs
+	}
+	return d.opts.clientCerts
+}
+
+func (d *BaseTLSDialer) handshakeTLS(ctx context.Context, conn net.Conn, host string) (*fingerConn, error) {
+	sni, verifyName := d.resolveServerNames(ctx, host)
+	if sni == verifyName {
+		d.opts.logger.Info(fmt.Sprintf("[TLS] 开始与 %s 进行TLS握手", sni))
+	} else {
+		d.opts.logger.Info(fmt.Sprintf("[TLS] 开始与 %s 进行TLS握手 (SNI: %q)", verifyName, sni))
+	}
 
 	config := &utls.Config{
-		ServerName:             serverName,
-		NextProtos:             []string{"h2", "http/1.1"},
-		InsecureSkipVerify:     true,
+		ServerName:             sni,
+		InsecureSkipVerify:     d.opts.insecureSkipVerify,
+		RootCAs:                d.opts.rootCAs,
+		VerifyPeerCertificate:  d.pinVerifier(verifyName, d.opts.verifyPeerCertificate),
+		VerifyConnection:       d.opts.verifyConnection,
+		Certificates:           d.clientCertificates(verifyName),
+		GetClientCertificate:   d.opts.getClientCertificate,
 		SessionTicketsDisabled: true,
 	}
+	if !d.opts.insecureSkipVerify && sni != verifyName {
+		// utls 只能按 ServerName 校验证书，SNI 与校验主机名不一致时改为手动校验
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = d.verifyWithName(verifyName, d.pinVerifier(verifyName, d.opts.verifyPeerCertificate))
+	}
 
 	uConn := utls.UClient(conn, config, utls.ClientHelloID{
 		Client:  "Custom",
@@ -50,41 +86,66 @@ func (d *BaseTLSDialer) handshakeTLS(ctx context.Context, conn net.Conn, serverN
 		Seed:    nil,
 	})
 
+	spec := d.specFactory(verifyName)()
+	adjustSNIExtension(spec, sni, d.explicitServerName(ctx))
+	config.NextProtos = d.applyALPN(ctx, spec)
+	if d.opts.strictSpec {
+		if warnings := fingerprint.Validate(spec); len(warnings) > 0 {
+			err := &fingerprint.ValidationError{Warnings: warnings}
+			d.opts.logger.Error("ClientHello规范校验失败", err)
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	d.opts.logger.Info("[TLS] 应用ClientHello预设...")
-	if err := uConn.ApplyPreset(d.opts.sf()); err != nil {
+	if err := uConn.ApplyPreset(spec); err != nil {
 		d.opts.logger.Error("应用ClientHello预设失败", err)
+		conn.Close()
 		return nil, fmt.Errorf("应用ClientHello预设失败: %w", err)
 	}
 
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- uConn.Handshake()
-	}()
+	if d.opts.handshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.handshakeTimeout)
+		defer cancel()
+	}
 
-	select {
-	case err := <-errChan:
-		if err != nil {
-			d.opts.logger.Error("TLS握手失败", err)
-			return nil, fmt.Errorf("TLS握手失败: %w", err)
+	// 握手在当前协程中进行，超时或取消时通过截止时间中断读写，避免遗留协程和连接
+	stop := netutil.WatchContext(ctx, conn)
+	if err := uConn.Handshake(); err != nil {
+		stop()
+		uConn.Close()
+		if err := netutil.ContextError(ctx, err); errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			d.opts.logger.Error("TLS握手超时或被取消", err)
+			return nil, err
 		}
-		state := uConn.ConnectionState()
-		d.opts.logger.Info(fmt.Sprintf("[TLS] 握手成功 - 协议: %s, 密码套件: %d", state.NegotiatedProtocol, state.CipherSuite))
-	case <-ctx.Done():
-		d.opts.logger.Error("TLS握手超时或被取消", ctx.Err())
-		return nil, ctx.Err()
+		d.opts.logger.Error("TLS握手失败", err)
+		return nil, fmt.Errorf("TLS握手失败: %w", err)
+	}
+	if err := stop(); err != nil {
+		d.opts.logger.Error("TLS握手超时或被取消", err)
+		uConn.Close()
+		return nil, err
 	}
 
-	return uConn, nil
+	state := uConn.ConnectionState()
+	d.opts.logger.Info(fmt.Sprintf("[TLS] 握手成功 - 协议: %s, 密码套件: %d", state.NegotiatedProtocol, state.CipherSuite))
+	return newFingerConn(uConn, spec), nil
 }
 
-func (d *BaseTLSDialer) DialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+func (d *BaseTLSDialer) DialTLS(ctx context.Context, network, addr string) (IFingerConn, error) {
 	d.opts.logger.Info(fmt.Sprintf("[TLS] 直接连接到 %s", addr))
 
-	tcpConn, err := (&net.Dialer{Timeout: d.opts.timeout}).DialContext(ctx, network, addr)
+	tcpConn, err := d.dialTCP(ctx, network, addr)
 	if err != nil {
 		d.opts.logger.Error(fmt.Sprintf("TCP连接到 %s 失败", addr), err)
 		return nil, err
 	}
 
-	return d.handshakeTLS(ctx, tcpConn, extractServerName(addr))
+	tlsConn, err := d.handshakeTLS(ctx, tcpConn, extractServerName(addr))
+	if err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
 }
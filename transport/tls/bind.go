@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/aberstone/fingertls/transport/internal/netutil"
+)
+
+// localAddrPool 出站连接的源地址池，按地址族分别轮询
+type localAddrPool struct {
+	v4, v6       []net.IP
+	next4, next6 atomic.Uint32
+}
+
+func newLocalAddrPool(ips []net.IP) *localAddrPool {
+	p := &localAddrPool{}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			p.v4 = append(p.v4, ip4)
+		} else if ip != nil {
+			p.v6 = append(p.v6, ip)
+		}
+	}
+	return p
+}
+
+// pick 返回与目标地址同一地址族的下一个源地址
+func (p *localAddrPool) pick(remote net.IP) (net.IP, error) {
+	if remote.To4() != nil {
+		if len(p.v4) == 0 {
+			return nil, fmt.Errorf("没有可用于连接 %s 的IPv4源地址", remote)
+		}
+		return p.v4[(p.next4.Add(1)-1)%uint32(len(p.v4))], nil
+	}
+	if len(p.v6) == 0 {
+		return nil, fmt.Errorf("没有可用于连接 %s 的IPv6源地址", remote)
+	}
+	return p.v6[(p.next6.Add(1)-1)%uint32(len(p.v6))], nil
+}
+
+// netDialer 返回连接 addr 使用的 net.Dialer，按配置绑定源地址和网络接口
+func (d *BaseTLSDialer) netDialer(addr string) (*net.Dialer, error) {
+	var localIP net.IP
+	if d.opts.localAddrs != nil {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if localIP, err = d.opts.localAddrs.pick(net.ParseIP(host)); err != nil {
+			return nil, err
+		}
+		d.opts.logger.Debug(fmt.Sprintf("[TLS] 使用源地址 %s 连接 %s", localIP, addr))
+	}
+	return netutil.NewDialer(localIP, d.opts.bindInterface), nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+func TestDialBindInterfaceError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// 接口不存在或没有 CAP_NET_RAW 权限时都应返回说明接口名的错误，而不是静默使用默认接口
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithInsecureSkipVerify(),
+		WithBindInterface("fingertls-none"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialTLS(context.Background(), "tcp", ln.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("DialTLS() 绑定不存在的网络接口应返回错误")
+	}
+	if !strings.Contains(err.Error(), "绑定网络接口 fingertls-none 失败") {
+		t.Errorf("DialTLS() error = %v, 应说明绑定网络接口失败", err)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !linux
+
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+func TestDialBindInterfaceUnsupported(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithInsecureSkipVerify(),
+		WithBindInterface("lo0"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialTLS(context.Background(), "tcp", ln.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("DialTLS() 非Linux平台绑定网络接口应返回错误")
+	}
+	if !strings.Contains(err.Error(), "绑定网络接口 lo0 失败: 仅支持 Linux") {
+		t.Errorf("DialTLS() error = %v, 应说明仅支持 Linux", err)
+	}
+}
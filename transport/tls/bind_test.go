@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+func TestLocalAddrPoolPick(t *testing.T) {
+	pool := newLocalAddrPool([]net.IP{
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("::ffff:192.0.2.3"), // IPv4映射地址归入IPv4
+	})
+	remote4, remote6 := net.ParseIP("198.51.100.1"), net.ParseIP("2001:db8:1::1")
+
+	// 两个地址族交替选择，各自轮询互不影响
+	want4 := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.1"}
+	want6 := []string{"2001:db8::1", "2001:db8::2", "2001:db8::1", "2001:db8::2"}
+	for i := range want4 {
+		ip, err := pool.pick(remote4)
+		if err != nil {
+			t.Fatalf("pick(%s) error = %v", remote4, err)
+		}
+		if ip.String() != want4[i] || ip.To4() == nil {
+			t.Errorf("第 %d 次 pick(%s) = %s, want %s", i+1, remote4, ip, want4[i])
+		}
+		ip, err = pool.pick(remote6)
+		if err != nil {
+			t.Fatalf("pick(%s) error = %v", remote6, err)
+		}
+		if ip.String() != want6[i] {
+			t.Errorf("第 %d 次 pick(%s) = %s, want %s", i+1, remote6, ip, want6[i])
+		}
+	}
+}
+
+func TestLocalAddrPoolMismatchedFamily(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  string
+		remote string
+		want   string
+	}{
+		{name: "只有IPv4源地址", local: "192.0.2.1", remote: "2001:db8::1", want: "没有可用于连接 2001:db8::1 的IPv6源地址"},
+		{name: "只有IPv6源地址", local: "2001:db8::1", remote: "198.51.100.1", want: "没有可用于连接 198.51.100.1 的IPv4源地址"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newLocalAddrPool([]net.IP{net.ParseIP(tt.local)})
+			ip, err := pool.pick(net.ParseIP(tt.remote))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("pick() = %v, %v, want error %q", ip, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialLocalAddr(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "origin.example", ca)
+	addr := serveTLS(t, leaf)
+
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithInsecureSkipVerify(),
+		WithLocalAddr(net.IPv4(127, 0, 0, 1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialTLS(context.Background(), "tcp", addr)
+	if err != nil {
+		t.Fatalf("DialTLS() error = %v", err)
+	}
+	defer conn.Close()
+
+	local, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok || !local.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("LocalAddr() = %v, want 127.0.0.1", conn.LocalAddr())
+	}
+}
+
+func TestDialLocalAddrMismatchedFamily(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("不支持IPv6回环地址: %v", err)
+	}
+	defer ln.Close()
+	accepted := make(chan struct{})
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+			close(accepted)
+		}
+	}()
+
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithInsecureSkipVerify(),
+		WithLocalAddr(net.IPv4(127, 0, 0, 1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialTLS(context.Background(), "tcp", ln.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("DialTLS() 源地址与目标地址族不一致时应返回错误")
+	}
+	if !strings.Contains(err.Error(), "IPv6源地址") {
+		t.Errorf("DialTLS() error = %v, 应说明缺少IPv6源地址", err)
+	}
+	ln.Close()
+	select {
+	case <-accepted:
+		t.Error("源地址与目标地址族不一致时不应发起连接")
+	default:
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
+)
+
+// fingerConn ITLSDialer 返回的连接，记录握手使用的指纹和代理路径
+type fingerConn struct {
+	*utls.UConn
+	spec        *utls.ClientHelloSpec
+	clientHello []byte
+	proxyPath   []*url.URL
+
+	fpOnce sync.Once
+	fp     *fingerprint.Fingerprint
+	fpErr  error
+}
+
+func newFingerConn(uConn *utls.UConn, spec *utls.ClientHelloSpec) *fingerConn {
+	c := &fingerConn{
+		UConn: uConn,
+		spec:  spec,
+	}
+	if uConn.HandshakeState.Hello != nil {
+		c.clientHello = slices.Clone(uConn.HandshakeState.Hello.Raw)
+	}
+	return c
+}
+
+func (c *fingerConn) NegotiatedProtocol() string {
+	return c.ConnectionState().NegotiatedProtocol
+}
+
+func (c *fingerConn) Spec() *utls.ClientHelloSpec {
+	return c.spec
+}
+
+func (c *fingerConn) ClientHello() []byte {
+	return c.clientHello
+}
+
+func (c *fingerConn) Fingerprint() (*fingerprint.Fingerprint, error) {
+	c.fpOnce.Do(func() {
+		c.fp, c.fpErr = fingerprint.ComputeFromClientHello(c.clientHello)
+	})
+	return c.fp, c.fpErr
+}
+
+func (c *fingerConn) ProxyPath() []*url.URL {
+	return stripProxyUserinfo(c.proxyPath)
+}
+
+// stripProxyUserinfo 复制代理地址并去掉其中的认证信息，避免通过连接泄露代理账号密码
+func stripProxyUserinfo(urls []*url.URL) []*url.URL {
+	if len(urls) == 0 {
+		return nil
+	}
+	out := make([]*url.URL, 0, len(urls))
+	for _, u := range urls {
+		stripped := *u
+		stripped.User = nil
+		out = append(out, &stripped)
+	}
+	return out
+}
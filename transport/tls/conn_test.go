@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestProxyPathStripsUserinfo(t *testing.T) {
+	chain := []*url.URL{
+		{Scheme: "http", User: url.UserPassword("user", "secret"), Host: "proxy1.example:8080"},
+		{Scheme: "socks5", User: url.User("token"), Host: "proxy2.example:1080"},
+	}
+	conn := &fingerConn{proxyPath: chain}
+
+	path := conn.ProxyPath()
+	if len(path) != len(chain) {
+		t.Fatalf("len(ProxyPath()) = %d, want %d", len(path), len(chain))
+	}
+	for i, u := range path {
+		if u.User != nil {
+			t.Errorf("ProxyPath()[%d] = %s, 不应包含认证信息", i, u)
+		}
+		if u.Host != chain[i].Host {
+			t.Errorf("ProxyPath()[%d].Host = %s, want %s", i, u.Host, chain[i].Host)
+		}
+	}
+	if chain[0].User == nil {
+		t.Error("ProxyPath() 修改了原始代理配置")
+	}
+
+	// 修改返回值不影响连接记录的代理路径
+	path[0].Host = "changed.example"
+	if got := conn.ProxyPath()[0].Host; got != "proxy1.example:8080" {
+		t.Errorf("ProxyPath()[0].Host = %s, want proxy1.example:8080", got)
+	}
+
+	if path := (&fingerConn{}).ProxyPath(); path != nil {
+		t.Errorf("直连时 ProxyPath() = %v, want nil", path)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// AddressFamily Happy Eyeballs 优先尝试的地址族
+type AddressFamily int
+
+const (
+	// PreferIPv6 优先尝试IPv6地址 (RFC 8305 推荐，与浏览器一致)
+	PreferIPv6 AddressFamily = iota
+	// PreferIPv4 优先尝试IPv4地址
+	PreferIPv4
+)
+
+// defaultFallbackDelay RFC 8305 推荐的连接尝试间隔
+const defaultFallbackDelay = 250 * time.Millisecond
+
+// dialResult 单个地址的连接结果
+type dialResult struct {
+	conn net.Conn
+	addr string
+	err  error
+}
+
+// dialTCP 按 RFC 8305 (Happy Eyeballs v2) 建立TCP连接: 解析出的地址按地址族交替排列，
+// 每隔 fallbackDelay 或上一个尝试失败时启动下一个尝试，最先成功的连接胜出，其余连接被取消并关闭。
+// 胜出的地址可通过返回连接的 RemoteAddr 获取
+func (d *BaseTLSDialer) dialTCP(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.opts.timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.lookupIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := sortAddrs(ips, d.opts.familyPreference, port)
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s 没有可用的 %s 地址", host, network)
+	}
+
+	conn, winner, err := d.dialParallel(ctx, network, addrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) > 1 {
+		d.opts.logger.Info(fmt.Sprintf("[TLS] Happy Eyeballs: 使用 %s (共 %d 个候选地址)", winner, len(addrs)))
+	}
+	return conn, nil
+}
+
+// lookupIP 使用配置的解析器解析主机名，IP地址直接返回
+func (d *BaseTLSDialer) lookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4":
+		ipNetwork = "ip4"
+	case "tcp6":
+		ipNetwork = "ip6"
+	}
+	if d.opts.resolver != nil {
+		return d.opts.resolver.LookupIP(ctx, ipNetwork, host)
+	}
+	return net.DefaultResolver.LookupIP(ctx, ipNetwork, host)
+}
+
+// dialParallel 依次错开启动连接尝试，返回最先成功的连接及其地址
+func (d *BaseTLSDialer) dialParallel(ctx context.Context, network string, addrs []string) (net.Conn, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fallbackDelay := d.opts.fallbackDelay
+	if fallbackDelay <= 0 {
+		fallbackDelay = defaultFallbackDelay
+	}
+
+	results := make(chan dialResult)
+	next, pending := 0, 0
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			var conn net.Conn
+			dialer, err := d.netDialer(addr)
+			if err == nil {
+				conn, err = dialer.DialContext(ctx, network, addr)
+			}
+			select {
+			case results <- dialResult{conn: conn, addr: addr, err: err}:
+			case <-ctx.Done():
+				// 已有连接胜出或拨号被取消，关闭迟到的连接
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	start()
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+
+	var errs []error
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.conn, res.addr, nil
+			}
+			errs = append(errs, res.err)
+			// 失败时立即尝试下一个地址
+			if next < len(addrs) {
+				start()
+				timer.Reset(fallbackDelay)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				start()
+				timer.Reset(fallbackDelay)
+			}
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+// sortAddrs 按 RFC 8305 第4节将地址按地址族交替排列，优先的地址族排在最前
+func sortAddrs(ips []net.IP, prefer AddressFamily, port string) []string {
+	var v4, v6 []string
+	for _, ip := range ips {
+		addr := net.JoinHostPort(ip.String(), port)
+		if ip.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+
+	first, second := v6, v4
+	if prefer == PreferIPv4 {
+		first, second = v4, v6
+	}
+	addrs := make([]string, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			addrs = append(addrs, first[i])
+		}
+		if i < len(second) {
+			addrs = append(addrs, second[i])
+		}
+	}
+	return addrs
+}
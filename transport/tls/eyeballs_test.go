@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/resolver"
+)
+
+func TestSortAddrs(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("192.0.2.2"),
+		net.ParseIP("2001:db8::1"),
+	}
+	tests := []struct {
+		name   string
+		prefer AddressFamily
+		want   []string
+	}{
+		{"IPv6优先", PreferIPv6, []string{"[2001:db8::1]:443", "192.0.2.1:443", "192.0.2.2:443"}},
+		{"IPv4优先", PreferIPv4, []string{"192.0.2.1:443", "[2001:db8::1]:443", "192.0.2.2:443"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortAddrs(ips, tt.prefer, "443")
+			if len(got) != len(tt.want) {
+				t.Fatalf("sortAddrs() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("sortAddrs() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHappyEyeballsLoopback(t *testing.T) {
+	if ln, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("环境不支持IPv6回环地址: %v", err)
+	} else {
+		ln.Close()
+	}
+
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "dual.example", ca)
+	v4 := serveTLS(t, leaf)
+	_, port, _ := net.SplitHostPort(v4)
+	v6 := net.JoinHostPort("::1", port)
+
+	tests := []struct {
+		name          string
+		listenV6      bool
+		prefer        AddressFamily
+		fallbackDelay time.Duration
+		want          string
+		maxElapsed    time.Duration
+	}{
+		// IPv6 连接被拒绝时立即尝试IPv4，不必等待 fallbackDelay
+		{name: "IPv6失败立即回退", prefer: PreferIPv6, fallbackDelay: 10 * time.Second, want: v4, maxElapsed: 2 * time.Second},
+		// IPv6 先启动并成功，fallbackDelay 内不会启动IPv4尝试
+		{name: "IPv6优先", listenV6: true, prefer: PreferIPv6, fallbackDelay: 10 * time.Second, want: v6, maxElapsed: 2 * time.Second},
+		{name: "IPv4优先", listenV6: true, prefer: PreferIPv4, fallbackDelay: 10 * time.Second, want: v4, maxElapsed: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.listenV6 {
+				serveTLSAt(t, v6, leaf)
+			}
+			hosts := resolver.NewHostsResolver(map[string][]net.IP{
+				"dual.example": {net.ParseIP("127.0.0.1"), net.IPv6loopback},
+			}, nil)
+			dialer, err := NewTLSDialerE(
+				WithLogger(logging.NewFakeLogger()),
+				WithInsecureSkipVerify(),
+				WithResolver(hosts),
+				WithAddressFamilyPreference(tt.prefer),
+				WithFallbackDelay(tt.fallbackDelay),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			conn, err := dialer.DialTLS(context.Background(), "tcp", net.JoinHostPort("dual.example", port))
+			if err != nil {
+				t.Fatalf("DialTLS() error = %v", err)
+			}
+			defer conn.Close()
+			if elapsed := time.Since(start); elapsed > tt.maxElapsed {
+				t.Errorf("DialTLS() 耗时 %v, 超过 %v", elapsed, tt.maxElapsed)
+			}
+			if got := conn.RemoteAddr().String(); got != tt.want {
+				t.Errorf("RemoteAddr() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHappyEyeballsFallbackDelay(t *testing.T) {
+	// 100::/64 为丢弃前缀 (RFC 6666)，连接该地址不会有响应，用来模拟无响应的IPv6地址
+	blackhole := net.JoinHostPort("100::1", "443")
+	if conn, err := net.DialTimeout("tcp6", blackhole, 50*time.Millisecond); err == nil {
+		conn.Close()
+		t.Skip("环境中 100::1 可以连接")
+	} else if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Skipf("环境中连接 100::1 不会阻塞: %v", err)
+	}
+
+	leaf := newTestCert(t, "dual.example", newTestCA(t))
+	v4 := serveTLS(t, leaf)
+	_, port, _ := net.SplitHostPort(v4)
+
+	const fallbackDelay = 300 * time.Millisecond
+	hosts := resolver.NewHostsResolver(map[string][]net.IP{
+		"dual.example": {net.ParseIP("100::1"), net.ParseIP("127.0.0.1")},
+	}, nil)
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithInsecureSkipVerify(),
+		WithResolver(hosts),
+		WithFallbackDelay(fallbackDelay),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialTLS(context.Background(), "tcp", net.JoinHostPort("dual.example", port))
+	if err != nil {
+		t.Fatalf("DialTLS() error = %v", err)
+	}
+	defer conn.Close()
+	elapsed := time.Since(start)
+	// IPv6 尝试无响应时，IPv4 尝试在 fallbackDelay 后启动并胜出
+	if elapsed < fallbackDelay || elapsed > fallbackDelay+time.Second {
+		t.Errorf("DialTLS() 耗时 %v, want 约 %v", elapsed, fallbackDelay)
+	}
+	if got := conn.RemoteAddr().String(); got != v4 {
+		t.Errorf("RemoteAddr() = %s, want %s", got, v4)
+	}
+}
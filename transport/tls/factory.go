@@ -18,20 +18,58 @@
 package tls
 
 import (
+	"crypto/x509"
+	"net"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/aberstone/fingertls/logging"
 	"github.com/aberstone/fingertls/transport/proxy_connector"
+	"github.com/aberstone/fingertls/transport/resolver"
 	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
 )
 
 type Options struct {
-	logger        logging.ILogger
-	sf            fingerprint.SpecFactory
-	timeout       time.Duration
-	upstreamProxy *url.URL
-	proxyTimeout  time.Duration
+	logger           logging.ILogger
+	sf               fingerprint.SpecFactory
+	pool             *fingerprint.Pool
+	strictSpec       bool
+	alpn             []string
+	timeout          time.Duration
+	proxyChain       []*url.URL
+	proxyTimeout     time.Duration
+	handshakeTimeout time.Duration
+	fallbackDelay    time.Duration
+	familyPreference AddressFamily
+	resolver         resolver.IResolver
+	proxyResolve     ProxyResolvePolicy
+	localAddrs       *localAddrPool
+	bindInterface    string
+
+	// SNI
+	serverName       string
+	omitServerName   bool
+	verifyServerName string
+
+	// 证书校验
+	insecureSkipVerify    bool
+	rootCAs               *x509.CertPool
+	verifyPeerCertificate func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+	verifyConnection      func(cs utls.ConnectionState) error
+	pins                  map[string][]string
+	pinReportOnly         bool
+
+	// HTTPS 代理
+	proxySF                 fingerprint.SpecFactory
+	proxyRootCAs            *x509.CertPool
+	proxyInsecureSkipVerify bool
+
+	// 客户端证书 (mTLS)
+	clientCerts          []utls.Certificate
+	hostClientCerts      map[string][]utls.Certificate
+	getClientCertificate func(*utls.CertificateRequestInfo) (*utls.Certificate, error)
 }
 
 type Option func(*Options)
@@ -41,44 +79,58 @@ func defaultOptions() *Options {
 	logger, _ := logging.NewZeroLogger(nil)
 
 	return &Options{
-		timeout:       30 * time.Second,
-		upstreamProxy: nil,
-		sf:            fingerprint.GetDefaultClientHelloSpec,
-		logger:        logger,
-		proxyTimeout:  30 * time.Second,
+		timeout:          30 * time.Second,
+		sf:               fingerprint.GetDefaultClientHelloSpec,
+		logger:           logger,
+		proxyTimeout:     30 * time.Second,
+		handshakeTimeout: 30 * time.Second,
+		fallbackDelay:    defaultFallbackDelay,
+		familyPreference: PreferIPv6,
 	}
 }
 
+// NewTLSDialer 创建TLS拨号器，配置无效时 panic；需要处理配置错误时使用 NewTLSDialerE
 func NewTLSDialer(opts ...Option) ITLSDialer {
+	dialer, err := NewTLSDialerE(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return dialer
+}
 
-	options := defaultOptions()
+// NewTLSDialerE 创建TLS拨号器，配置无效时返回由 *OptionError 组成的错误
+func NewTLSDialerE(opts ...Option) (ITLSDialer, error) {
+	options := NewOptions(opts...)
+	if err := options.Validate(); err != nil {
+		if options.logger != nil {
+			options.logger.Error("TLS拨号器配置无效", err)
+		}
+		return nil, err
+	}
 
-	for _, opt := range opts {
-		opt(options)
+	if options.insecureSkipVerify {
+		options.logger.Warn("[TLS] 已禁用服务器证书校验，连接可能遭受中间人攻击")
+	}
+	if options.proxyInsecureSkipVerify {
+		options.logger.Warn("[TLS] 已禁用HTTPS代理服务器证书校验，连接可能遭受中间人攻击")
 	}
 
-	var connector proxy_connector.ProxyConnector
-	if options.upstreamProxy != nil {
-		switch options.upstreamProxy.Scheme {
-		case "http":
-			connector = proxy_connector.NewHTTPProxyConnector(options.proxyTimeout, options.logger)
-		case "socks5":
-			connector = proxy_connector.NewSocks5ProxyConnector(options.proxyTimeout, options.logger)
-		default:
-			options.logger.Error("不支持的代理协议", nil)
-			panic("不支持的代理协议")
-		}
+	base := &BaseTLSDialer{
+		opts: options,
+	}
+
+	if len(options.proxyChain) > 0 {
+		// 连接第一跳代理服务器同样使用拨号器的域名解析和 Happy Eyeballs
+		connector := proxy_connector.NewChainProxyConnector(options.proxyTimeout, options.logger,
+			proxy_connector.WithDialFunc(base.dialTCP),
+			proxy_connector.WithTLSWrapFunc(proxyTLSWrapper(options)))
 		return &ProxyTLSDialer{
-			&BaseTLSDialer{
-				opts: options,
-			},
+			base,
 			connector,
-		}
+		}, nil
 	}
 
-	return &BaseTLSDialer{
-		opts: options,
-	}
+	return base, nil
 }
 
 func WithLogger(logger logging.ILogger) Option {
@@ -87,6 +139,7 @@ func WithLogger(logger logging.ILogger) Option {
 	}
 }
 
+// WithTimeout 设置与目标服务器建立TCP连接的超时时间
 func WithTimeout(timeout time.Duration) Option {
 	return func(opts *Options) {
 		opts.timeout = timeout
@@ -94,7 +147,19 @@ func WithTimeout(timeout time.Duration) Option {
 }
 func WithUpstreamProxy(upstreamProxy *url.URL) Option {
 	return func(opts *Options) {
-		opts.upstreamProxy = upstreamProxy
+		if upstreamProxy == nil {
+			opts.proxyChain = nil
+			return
+		}
+		opts.proxyChain = []*url.URL{upstreamProxy}
+	}
+}
+
+// WithProxyChain 依次通过多个代理连接目标服务器，HTTP、HTTPS 与 SOCKS5 代理可以混用，与 WithUpstreamProxy 互相覆盖。
+// 每一跳使用各自URL中的认证信息，超时默认为 WithProxyTimeout，可通过URL参数 timeout (如 "?timeout=5s") 单独设置
+func WithProxyChain(chain []*url.URL) Option {
+	return func(opts *Options) {
+		opts.proxyChain = append([]*url.URL(nil), chain...)
 	}
 }
 func WithSpecFactory(sf fingerprint.SpecFactory) Option {
@@ -102,6 +167,212 @@ func WithSpecFactory(sf fingerprint.SpecFactory) Option {
 		opts.sf = sf
 	}
 }
+
+// WithSpecPool 每次连接时从指纹池中选择ClientHello，设置后优先于 WithSpecFactory
+func WithSpecPool(pool *fingerprint.Pool) Option {
+	return func(opts *Options) {
+		opts.pool = pool
+	}
+}
+
+// WithStrictSpec 握手前使用 fingerprint.Validate 校验ClientHello，发现问题时放弃连接并返回 *fingerprint.ValidationError
+func WithStrictSpec() Option {
+	return func(opts *Options) {
+		opts.strictSpec = true
+	}
+}
+
+// WithRootCAs 使用自定义的根证书池校验服务器证书，未设置时使用系统根证书
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(opts *Options) {
+		opts.rootCAs = pool
+	}
+}
+
+// WithSystemRoots 使用系统根证书校验服务器证书 (默认行为)，用于覆盖之前设置的 WithRootCAs
+func WithSystemRoots() Option {
+	return func(opts *Options) {
+		opts.rootCAs = nil
+	}
+}
+
+// WithVerifyPeerCertificate 设置额外的证书校验回调，在标准证书链校验之后调用；
+// 与 WithInsecureSkipVerify 同时使用时 verifiedChains 为空
+func WithVerifyPeerCertificate(fn func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error) Option {
+	return func(opts *Options) {
+		opts.verifyPeerCertificate = fn
+	}
+}
+
+// WithVerifyConnection 设置握手完成前的连接校验回调，无论是否跳过证书校验都会调用
+func WithVerifyConnection(fn func(cs utls.ConnectionState) error) Option {
+	return func(opts *Options) {
+		opts.verifyConnection = fn
+	}
+}
+
+// WithInsecureSkipVerify 跳过服务器证书校验，存在中间人攻击风险，仅用于调试或已通过其他方式保证安全的场景
+func WithInsecureSkipVerify() Option {
+	return func(opts *Options) {
+		opts.insecureSkipVerify = true
+	}
+}
+
+// WithPinnedKeys 为主机固定服务器证书公钥，pins 为 SPKI 的 SHA-256 哈希 (base64，可带 "sha256/" 前缀)。
+// 校验后的证书链中任意一个公钥匹配即通过，跳过证书校验时只匹配服务器的叶子证书；
+// 备用公钥直接一并传入，多次调用同一主机会追加公钥
+func WithPinnedKeys(host string, pins ...string) Option {
+	return func(opts *Options) {
+		if opts.pins == nil {
+			opts.pins = make(map[string][]string)
+		}
+		host = strings.ToLower(host)
+		for _, pin := range pins {
+			opts.pins[host] = append(opts.pins[host], normalizePin(pin))
+		}
+	}
+}
+
+// WithPinReportOnly 公钥固定校验失败时只通过日志报告，不中断连接
+func WithPinReportOnly() Option {
+	return func(opts *Options) {
+		opts.pinReportOnly = true
+	}
+}
+
+// WithClientCertificates 设置双向TLS使用的客户端证书链，服务器请求证书时按其支持的签名算法选择
+func WithClientCertificates(certs ...utls.Certificate) Option {
+	return func(opts *Options) {
+		opts.clientCerts = append(opts.clientCerts, certs...)
+	}
+}
+
+// WithHostClientCertificates 为指定主机设置客户端证书链，优先于 WithClientCertificates。
+// 主机按证书校验使用的主机名 (见 WithVerifyServerName) 匹配，不区分大小写
+func WithHostClientCertificates(host string, certs ...utls.Certificate) Option {
+	return func(opts *Options) {
+		if opts.hostClientCerts == nil {
+			opts.hostClientCerts = make(map[string][]utls.Certificate)
+		}
+		host = strings.ToLower(host)
+		opts.hostClientCerts[host] = append(opts.hostClientCerts[host], certs...)
+	}
+}
+
+// WithGetClientCertificate 设置动态选择客户端证书的回调，设置后忽略 WithClientCertificates 和 WithHostClientCertificates
+func WithGetClientCertificate(fn func(*utls.CertificateRequestInfo) (*utls.Certificate, error)) Option {
+	return func(opts *Options) {
+		opts.getClientCertificate = fn
+	}
+}
+
+// WithServerName 覆盖握手时发送的SNI，默认使用拨号地址中的主机名
+func WithServerName(serverName string) Option {
+	return func(opts *Options) {
+		opts.serverName = serverName
+	}
+}
+
+// WithoutServerName 握手时不发送SNI，并从指纹中移除 server_name 扩展
+func WithoutServerName() Option {
+	return func(opts *Options) {
+		opts.omitServerName = true
+	}
+}
+
+// WithVerifyServerName 指定证书校验使用的主机名，可与发送的SNI不同 (如域前置)
+func WithVerifyServerName(verifyName string) Option {
+	return func(opts *Options) {
+		opts.verifyServerName = verifyName
+	}
+}
+
+// WithALPN 强制使用指定的ALPN协议列表并改写指纹中的 ALPN 扩展，默认使用指纹中的 ALPN
+func WithALPN(protos ...string) Option {
+	return func(opts *Options) {
+		opts.alpn = protos
+	}
+}
+
+// WithHandshakeTimeout 设置TLS握手阶段的超时时间，为0时只受 ctx 控制
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.handshakeTimeout = timeout
+	}
+}
+
+// WithFallbackDelay 设置 Happy Eyeballs 启动下一个连接尝试前的等待时间，默认 250ms
+func WithFallbackDelay(delay time.Duration) Option {
+	return func(opts *Options) {
+		opts.fallbackDelay = delay
+	}
+}
+
+// WithAddressFamilyPreference 设置 Happy Eyeballs 优先尝试的地址族，默认优先IPv6
+func WithAddressFamilyPreference(family AddressFamily) Option {
+	return func(opts *Options) {
+		opts.familyPreference = family
+	}
+}
+
+// WithResolver 设置域名解析器，默认使用系统解析器。可配合 resolver.NewCachingResolver 缓存解析结果
+func WithResolver(r resolver.IResolver) Option {
+	return func(opts *Options) {
+		opts.resolver = r
+	}
+}
+
+// WithProxyResolvePolicy 设置通过代理连接时目标主机名的解析方式，默认由代理服务器解析
+func WithProxyResolvePolicy(policy ProxyResolvePolicy) Option {
+	return func(opts *Options) {
+		opts.proxyResolve = policy
+	}
+}
+
+// WithLocalAddr 绑定出站连接的源地址，连接与其地址族不同的目标时失败
+func WithLocalAddr(ip net.IP) Option {
+	return func(opts *Options) {
+		opts.localAddrs = newLocalAddrPool([]net.IP{ip})
+	}
+}
+
+// WithLocalAddrPool 从多个源地址中按地址族轮询选择出站连接的源地址
+func WithLocalAddrPool(ips ...net.IP) Option {
+	return func(opts *Options) {
+		opts.localAddrs = newLocalAddrPool(ips)
+	}
+}
+
+// WithBindInterface 通过 SO_BINDTODEVICE 将出站连接绑定到指定网络接口 (仅Linux，通常需要 CAP_NET_RAW 权限)
+func WithBindInterface(name string) Option {
+	return func(opts *Options) {
+		opts.bindInterface = name
+	}
+}
+
+// WithProxySpecFactory 设置与HTTPS代理服务器握手使用的指纹，默认与 WithSpecFactory 相同。
+// 代理握手的ALPN固定为 http/1.1
+func WithProxySpecFactory(sf fingerprint.SpecFactory) Option {
+	return func(opts *Options) {
+		opts.proxySF = sf
+	}
+}
+
+// WithProxyRootCAs 使用自定义的根证书池校验HTTPS代理服务器的证书，未设置时使用系统根证书
+func WithProxyRootCAs(pool *x509.CertPool) Option {
+	return func(opts *Options) {
+		opts.proxyRootCAs = pool
+	}
+}
+
+// WithProxyInsecureSkipVerify 跳过HTTPS代理服务器的证书校验，不影响目标服务器的证书校验
+func WithProxyInsecureSkipVerify() Option {
+	return func(opts *Options) {
+		opts.proxyInsecureSkipVerify = true
+	}
+}
+
+// WithProxyTimeout 设置连接代理服务器及代理协商阶段的超时时间，使用代理链时分别作用于每一跳
 func WithProxyTimeout(timeout time.Duration) Option {
 	return func(opts *Options) {
 		opts.proxyTimeout = timeout
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// ErrUnsupportedClientHello 抓包中的ClientHello可以解析，但 utls 无法按其生成握手消息
+var ErrUnsupportedClientHello = errors.New("utls 无法生成该ClientHello")
+
+type captureOptions struct {
+	allowUnknownExtensions bool
+	realPSK                bool
+}
+
+// CaptureOption 控制从抓包数据导入ClientHello时的行为
+type CaptureOption func(*captureOptions)
+
+// WithUnknownExtensions 将 utls 无法识别的扩展按原始字节原样保留，默认返回错误。
+// 原样保留的扩展不会参与握手逻辑，服务端对其作出响应时可能导致握手失败。
+func WithUnknownExtensions() CaptureOption {
+	return func(o *captureOptions) {
+		o.allowUnknownExtensions = true
+	}
+}
+
+// WithRealPSK 将 pre_shared_key 扩展导入为可用于会话恢复的真实PSK，默认导入为伪造PSK
+func WithRealPSK() CaptureOption {
+	return func(o *captureOptions) {
+		o.realPSK = true
+	}
+}
+
+// FromClientHelloBytes 将抓包得到的ClientHello报文转换为 SpecFactory，报文可以带TLS记录层头部，
+// 也可以只包含握手消息。使用 utls 的 Fingerprinter 解析，保留扩展顺序、GREASE位置、
+// 填充扩展以及密钥共享的曲线；密钥共享的公钥在每次握手时重新生成。
+func FromClientHelloBytes(raw []byte, opts ...CaptureOption) (SpecFactory, error) {
+	options := &captureOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	record := withRecordHeader(raw)
+	fingerprinter := &utls.Fingerprinter{
+		AllowBluntMimicry: options.allowUnknownExtensions,
+		RealPSKResumption: options.realPSK,
+	}
+	if _, err := fingerprinter.RawClientHello(record); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClientHello, err)
+	}
+
+	sf := func() *utls.ClientHelloSpec {
+		// utls 会在握手时修改扩展内容，每次重新解析以返回独立的实例
+		spec, _ := fingerprinter.RawClientHello(record)
+		return spec
+	}
+
+	// 预先构造一次，能解析但无法生成的ClientHello (如 utls 不支持的密钥共享曲线) 在导入时报错，而不是在每次握手时失败
+	if _, err := marshalClientHello(sf, computeServerName); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedClientHello, err)
+	}
+	return sf, nil
+}
+
+// withRecordHeader 返回带TLS记录层头部的ClientHello副本，utls 的解析器要求输入完整的记录
+func withRecordHeader(raw []byte) []byte {
+	if len(raw) > 5 && raw[0] == recordTypeHandshake && raw[1] == 0x03 {
+		return append([]byte(nil), raw...)
+	}
+	record := make([]byte, 5+len(raw))
+	record[0] = recordTypeHandshake
+	binary.BigEndian.PutUint16(record[1:3], utls.VersionTLS10)
+	binary.BigEndian.PutUint16(record[3:5], uint16(len(raw)))
+	copy(record[5:], raw)
+	return record
+}
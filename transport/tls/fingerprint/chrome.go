@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	utls "github.com/refraction-networking/utls"
+)
+
+// GetChrome120ClientHelloSpec 返回 Chrome 120 (Windows/macOS/Linux) 的ClientHello规范
+func GetChrome120ClientHelloSpec() *utls.ClientHelloSpec {
+	return chromiumClientHelloSpec(
+		[]utls.CurveID{
+			utls.GREASE_PLACEHOLDER,
+			utls.X25519,    // 29
+			utls.CurveP256, // 23
+			utls.CurveP384, // 24
+		},
+		[]utls.KeyShare{
+			{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}},
+			{Group: utls.X25519},
+		},
+	)
+}
+
+// GetChrome124ClientHelloSpec 返回 Chrome 124 (Windows/macOS/Linux) 的ClientHello规范
+// Chrome 124 起默认启用 X25519Kyber768Draft00 混合密钥交换
+func GetChrome124ClientHelloSpec() *utls.ClientHelloSpec {
+	return chromiumClientHelloSpec(
+		[]utls.CurveID{
+			utls.GREASE_PLACEHOLDER,
+			utls.X25519Kyber768Draft00, // 25497
+			utls.X25519,                // 29
+			utls.CurveP256,             // 23
+			utls.CurveP384,             // 24
+		},
+		[]utls.KeyShare{
+			{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}},
+			{Group: utls.X25519Kyber768Draft00},
+			{Group: utls.X25519},
+		},
+	)
+}
+
+// GetChromeAndroid120ClientHelloSpec 返回 Android 版 Chrome 120 的ClientHello规范
+// Android 与桌面版共用 BoringSSL 配置，ClientHello 与桌面版一致
+func GetChromeAndroid120ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome120ClientHelloSpec()
+}
+
+// GetEdge120ClientHelloSpec 返回 Edge 120 的ClientHello规范
+// Edge 120 基于 Chromium 120，ClientHello 与同版本 Chrome 一致
+func GetEdge120ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome120ClientHelloSpec()
+}
+
+// GetChromeAndroid124ClientHelloSpec 返回 Android 版 Chrome 124 的ClientHello规范
+// 与桌面版一致，同样默认启用 X25519Kyber768Draft00
+func GetChromeAndroid124ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome124ClientHelloSpec()
+}
+
+// GetEdge124ClientHelloSpec 返回 Edge 124 的ClientHello规范
+// Edge 124 基于 Chromium 124，默认启用 X25519Kyber768Draft00
+func GetEdge124ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome124ClientHelloSpec()
+}
+
+// GetChrome131ClientHelloSpec 返回 Chrome 131 (Windows/macOS/Linux) 的ClientHello规范
+// Chrome 131 起以标准化的 X25519MLKEM768 取代 X25519Kyber768Draft00
+func GetChrome131ClientHelloSpec() *utls.ClientHelloSpec {
+	return chromiumClientHelloSpec(
+		[]utls.CurveID{
+			utls.GREASE_PLACEHOLDER,
+			utls.X25519MLKEM768, // 4588
+			utls.X25519,         // 29
+			utls.CurveP256,      // 23
+			utls.CurveP384,      // 24
+		},
+		[]utls.KeyShare{
+			{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}},
+			{Group: utls.X25519MLKEM768},
+			{Group: utls.X25519},
+		},
+	)
+}
+
+// GetChromeAndroid131ClientHelloSpec 返回 Android 版 Chrome 131 的ClientHello规范
+// 与桌面版一致，默认启用 X25519MLKEM768
+func GetChromeAndroid131ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome131ClientHelloSpec()
+}
+
+// GetEdge131ClientHelloSpec 返回 Edge 131 的ClientHello规范
+// Edge 131 基于 Chromium 131，默认启用 X25519MLKEM768
+func GetEdge131ClientHelloSpec() *utls.ClientHelloSpec {
+	return GetChrome131ClientHelloSpec()
+}
+
+// chromiumClientHelloSpec 构造 Chromium 系浏览器的ClientHello规范，
+// 各版本之间仅支持的曲线和密钥共享不同
+func chromiumClientHelloSpec(curves []utls.CurveID, keyShares []utls.KeyShare) *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		TLSVersMin: utls.VersionTLS12,
+		TLSVersMax: utls.VersionTLS13,
+		CipherSuites: []uint16{
+			utls.GREASE_PLACEHOLDER,                      // GREASE前缀
+			utls.TLS_AES_128_GCM_SHA256,                  // 4865
+			utls.TLS_AES_256_GCM_SHA384,                  // 4866
+			utls.TLS_CHACHA20_POLY1305_SHA256,            // 4867
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // 49195
+			utls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,   // 49199
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, // 49196
+			utls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,   // 49200
+			utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,  // 52393
+			utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,    // 52392
+			utls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,      // 49171
+			utls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,      // 49172
+			utls.TLS_RSA_WITH_AES_128_GCM_SHA256,         // 156
+			utls.TLS_RSA_WITH_AES_256_GCM_SHA384,         // 157
+			utls.TLS_RSA_WITH_AES_128_CBC_SHA,            // 47
+			utls.TLS_RSA_WITH_AES_256_CBC_SHA,            // 53
+		},
+		CompressionMethods: []byte{0}, // 无压缩
+		Extensions: []utls.TLSExtension{
+			&utls.UtlsGREASEExtension{},
+			&utls.SNIExtension{},                  // 0
+			&utls.ExtendedMasterSecretExtension{}, // 23
+			&utls.RenegotiationInfoExtension{ // 65281
+				Renegotiation: utls.RenegotiateOnceAsClient},
+			&utls.SupportedCurvesExtension{ // 10
+				Curves: curves},
+			&utls.SupportedPointsExtension{ // 11
+				SupportedPoints: []byte{0}},
+			&utls.SessionTicketExtension{}, // 35
+			&utls.ALPNExtension{ // 16
+				AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.StatusRequestExtension{}, // 5
+			&utls.SignatureAlgorithmsExtension{ // 13
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.PSSWithSHA256,
+					utls.PKCS1WithSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.PSSWithSHA384,
+					utls.PKCS1WithSHA384,
+					utls.PSSWithSHA512,
+					utls.PKCS1WithSHA512,
+				}},
+			&utls.SCTExtension{}, // 18
+			&utls.KeyShareExtension{ // 51
+				KeyShares: keyShares},
+			&utls.PSKKeyExchangeModesExtension{ // 45
+				Modes: []uint8{utls.PskModeDHE}},
+			&utls.SupportedVersionsExtension{ // 43
+				Versions: []uint16{
+					utls.GREASE_PLACEHOLDER,
+					utls.VersionTLS13,
+					utls.VersionTLS12}},
+			&utls.UtlsCompressCertExtension{ // 27
+				Algorithms: []utls.CertCompressionAlgo{
+					utls.CertCompressionBrotli,
+				}},
+			&utls.ApplicationSettingsExtension{ // 17513
+				SupportedProtocols: []string{"h2"}},
+			utls.BoringGREASEECH(), // 65037
+			&utls.UtlsGREASEExtension{},
+		}}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// ErrInvalidClientHello 表示ClientHello报文无法解析
+var ErrInvalidClientHello = errors.New("无效的ClientHello")
+
+const (
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+)
+
+// rawExtension ClientHello中按原始顺序排列的扩展
+type rawExtension struct {
+	id   uint16
+	data []byte
+}
+
+// clientHello 解析后的ClientHello字段，仅包含计算指纹所需的内容
+type clientHello struct {
+	version             uint16
+	cipherSuites        []uint16
+	compressionMethods  []uint8
+	extensions          []rawExtension
+	serverName          string
+	supportedGroups     []uint16
+	pointFormats        []uint8
+	signatureAlgorithms []uint16
+	alpn                []string
+	supportedVersions   []uint16
+}
+
+// parseClientHello 解析ClientHello报文，输入可以带TLS记录层头部，也可以只包含握手消息
+func parseClientHello(raw []byte) (*clientHello, error) {
+	// 跳过TLS记录层头部: 类型(1) + 版本(2) + 长度(2)
+	if len(raw) > 5 && raw[0] == recordTypeHandshake && raw[1] == 0x03 {
+		raw = raw[5:]
+	}
+
+	s := cryptobyte.String(raw)
+	var msgType uint8
+	var body cryptobyte.String
+	if !s.ReadUint8(&msgType) || msgType != handshakeTypeClientHello {
+		return nil, fmt.Errorf("%w: 不是ClientHello消息", ErrInvalidClientHello)
+	}
+	if !s.ReadUint24LengthPrefixed(&body) {
+		return nil, fmt.Errorf("%w: 消息长度不足", ErrInvalidClientHello)
+	}
+
+	hello := &clientHello{}
+	var random []byte
+	var sessionID, ciphers, compression cryptobyte.String
+	if !body.ReadUint16(&hello.version) ||
+		!body.ReadBytes(&random, 32) ||
+		!body.ReadUint8LengthPrefixed(&sessionID) ||
+		!body.ReadUint16LengthPrefixed(&ciphers) ||
+		!body.ReadUint8LengthPrefixed(&compression) {
+		return nil, fmt.Errorf("%w: 报文头部格式错误", ErrInvalidClientHello)
+	}
+	for !ciphers.Empty() {
+		var c uint16
+		if !ciphers.ReadUint16(&c) {
+			return nil, fmt.Errorf("%w: 密码套件格式错误", ErrInvalidClientHello)
+		}
+		hello.cipherSuites = append(hello.cipherSuites, c)
+	}
+	hello.compressionMethods = append([]uint8(nil), compression...)
+
+	if body.Empty() {
+		return hello, nil
+	}
+	var exts cryptobyte.String
+	if !body.ReadUint16LengthPrefixed(&exts) {
+		return nil, fmt.Errorf("%w: 扩展格式错误", ErrInvalidClientHello)
+	}
+	for !exts.Empty() {
+		var id uint16
+		var data cryptobyte.String
+		if !exts.ReadUint16(&id) || !exts.ReadUint16LengthPrefixed(&data) {
+			return nil, fmt.Errorf("%w: 扩展格式错误", ErrInvalidClientHello)
+		}
+		hello.extensions = append(hello.extensions, rawExtension{id: id, data: append([]byte(nil), data...)})
+		if err := hello.decodeExtension(id, data); err != nil {
+			return nil, err
+		}
+	}
+	return hello, nil
+}
+
+// decodeExtension 解码指纹计算需要的扩展内容
+func (h *clientHello) decodeExtension(id uint16, data cryptobyte.String) error {
+	var ok bool
+	switch id {
+	case extServerName:
+		var list cryptobyte.String
+		ok = data.ReadUint16LengthPrefixed(&list)
+		for ok && !list.Empty() {
+			var nameType uint8
+			var name cryptobyte.String
+			if ok = list.ReadUint8(&nameType) && list.ReadUint16LengthPrefixed(&name); ok && nameType == 0 {
+				h.serverName = string(name)
+			}
+		}
+	case extSupportedGroups:
+		h.supportedGroups, ok = readUint16List(data)
+	case extECPointFormats:
+		var points cryptobyte.String
+		if ok = data.ReadUint8LengthPrefixed(&points); ok {
+			h.pointFormats = append([]uint8(nil), points...)
+		}
+	case extSignatureAlgorithms:
+		h.signatureAlgorithms, ok = readUint16List(data)
+	case extALPN:
+		var list cryptobyte.String
+		ok = data.ReadUint16LengthPrefixed(&list)
+		for ok && !list.Empty() {
+			var proto cryptobyte.String
+			if ok = list.ReadUint8LengthPrefixed(&proto); ok {
+				h.alpn = append(h.alpn, string(proto))
+			}
+		}
+	case extSupportedVersions:
+		var list cryptobyte.String
+		ok = data.ReadUint8LengthPrefixed(&list)
+		for ok && !list.Empty() {
+			var v uint16
+			if ok = list.ReadUint16(&v); ok {
+				h.supportedVersions = append(h.supportedVersions, v)
+			}
+		}
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("%w: 扩展 %d 格式错误", ErrInvalidClientHello, id)
+	}
+	return nil
+}
+
+func readUint16List(data cryptobyte.String) ([]uint16, bool) {
+	var list cryptobyte.String
+	if !data.ReadUint16LengthPrefixed(&list) {
+		return nil, false
+	}
+	var values []uint16
+	for !list.Empty() {
+		var v uint16
+		if !list.ReadUint16(&v) {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, true
+}
+
+// marshalClientHello 通过 utls 将 SpecFactory 序列化为ClientHello握手消息，不建立任何网络连接
+func marshalClientHello(sf SpecFactory, serverName string) (raw []byte, err error) {
+	spec := sf()
+	if err := checkPreSharedKeyLast(spec); err != nil {
+		return nil, err
+	}
+
+	// utls 对部分非法规范使用断言直接 panic，这里统一转换为错误
+	defer func() {
+		if r := recover(); r != nil {
+			raw, err = nil, fmt.Errorf("构造ClientHello失败: %v", r)
+		}
+	}()
+
+	uConn := utls.UClient(nil, &utls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	}, utls.HelloCustom)
+
+	if err := uConn.ApplyPreset(spec); err != nil {
+		return nil, fmt.Errorf("应用ClientHello预设失败: %w", err)
+	}
+	if err := uConn.BuildHandshakeState(); err != nil {
+		return nil, fmt.Errorf("构造ClientHello失败: %w", err)
+	}
+	return uConn.HandshakeState.Hello.Raw, nil
+}
+
+// checkPreSharedKeyLast 检查 pre_shared_key 是否为最后一个扩展，utls 在应用预设时遇到该错误会直接 panic
+func checkPreSharedKeyLast(spec *utls.ClientHelloSpec) error {
+	for i, ext := range spec.Extensions {
+		if _, ok := ext.(utls.PreSharedKeyExtension); ok && i != len(spec.Extensions)-1 {
+			return errors.New("pre_shared_key 必须是最后一个扩展")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// computeServerName 离线计算指纹时使用的SNI，只影响是否携带SNI扩展，不影响指纹结果
+const computeServerName = "example.com"
+
+// Fingerprint ClientHello的JA3/JA4指纹
+type Fingerprint struct {
+	JA3     string // JA3原始字符串
+	JA3Hash string // JA3字符串的MD5
+	JA4     string // JA4指纹
+	JA4R    string // JA4_r，JA4的原始 (未哈希) 形式
+}
+
+// Compute 离线计算 SpecFactory 生成的ClientHello的JA3/JA4指纹，不建立网络连接。
+// GREASE值按照JA3/JA4算法的规定剔除，因此结果与每次握手随机生成的GREASE值无关。
+func Compute(sf SpecFactory) (*Fingerprint, error) {
+	raw, err := marshalClientHello(sf, computeServerName)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeFromClientHello(raw)
+}
+
+// ComputeFromClientHello 根据原始ClientHello报文计算JA3/JA4指纹，报文可以带TLS记录层头部
+func ComputeFromClientHello(raw []byte) (*Fingerprint, error) {
+	hello, err := parseClientHello(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ja3 := hello.ja3()
+	sum := md5.Sum([]byte(ja3))
+	ja4, ja4r := hello.ja4()
+	return &Fingerprint{
+		JA3:     ja3,
+		JA3Hash: hex.EncodeToString(sum[:]),
+		JA4:     ja4,
+		JA4R:    ja4r,
+	}, nil
+}
+
+// ja3 按 SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats 格式生成JA3字符串
+func (h *clientHello) ja3() string {
+	extIDs := make([]uint16, 0, len(h.extensions))
+	for _, ext := range h.extensions {
+		extIDs = append(extIDs, ext.id)
+	}
+	points := make([]uint16, 0, len(h.pointFormats))
+	for _, p := range h.pointFormats {
+		points = append(points, uint16(p))
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(h.version)),
+		joinDecimal(h.cipherSuites),
+		joinDecimal(extIDs),
+		joinDecimal(h.supportedGroups),
+		joinDecimal(points),
+	}, ",")
+}
+
+// ja4 生成JA4和JA4_r指纹，参见 https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+func (h *clientHello) ja4() (string, string) {
+	ciphers := withoutGREASE(h.cipherSuites)
+
+	var extIDs []uint16
+	var sortedExts []uint16
+	for _, ext := range h.extensions {
+		if isGREASE(ext.id) {
+			continue
+		}
+		extIDs = append(extIDs, ext.id)
+		// SNI和ALPN计入扩展数量，但不参与哈希
+		if ext.id != extServerName && ext.id != extALPN {
+			sortedExts = append(sortedExts, ext.id)
+		}
+	}
+
+	sni := "i"
+	for _, id := range extIDs {
+		if id == extServerName {
+			sni = "d"
+			break
+		}
+	}
+
+	a := fmt.Sprintf("t%s%s%02d%02d%s",
+		ja4Version(h.tlsVersion()), sni, min(len(ciphers), 99), min(len(extIDs), 99), ja4ALPN(h.alpn))
+
+	sortedCiphers := append([]uint16(nil), ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	sort.Slice(sortedExts, func(i, j int) bool { return sortedExts[i] < sortedExts[j] })
+
+	b := joinHex(sortedCiphers)
+	c := joinHex(sortedExts)
+	if sigs := withoutGREASE(h.signatureAlgorithms); len(sigs) > 0 {
+		c += "_" + joinHex(sigs)
+	}
+
+	ja4 := a + "_" + ja4Hash(b, len(sortedCiphers) == 0) + "_" + ja4Hash(c, len(sortedExts) == 0)
+	ja4r := a + "_" + b + "_" + c
+	return ja4, ja4r
+}
+
+// tlsVersion 返回ClientHello声明的最高TLS版本，优先取 supported_versions 扩展
+func (h *clientHello) tlsVersion() uint16 {
+	var highest uint16
+	for _, v := range withoutGREASE(h.supportedVersions) {
+		if v > highest {
+			highest = v
+		}
+	}
+	if highest == 0 {
+		return h.version
+	}
+	return highest
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	case 0xfeff:
+		return "d1"
+	case 0xfefd:
+		return "d2"
+	case 0xfefc:
+		return "d3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN 取第一个ALPN协议的首尾字符，非字母数字时取其十六进制表示的首尾字符
+func ja4ALPN(alpn []string) string {
+	if len(alpn) == 0 || alpn[0] == "" {
+		return "00"
+	}
+	proto := alpn[0]
+	first, last := proto[0], proto[len(proto)-1]
+	if isAlnum(first) && isAlnum(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(proto))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func ja4Hash(s string, empty bool) string {
+	if empty {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func isAlnum(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	out := make([]uint16, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range withoutGREASE(values) {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Difference 两个指纹之间的一处差异，Field 为差异所在的字段路径，A/B 为两侧的取值
+type Difference struct {
+	Field string
+	A     string
+	B     string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Field, d.A, d.B)
+}
+
+// absent 表示某一侧不存在该字段
+const absent = "(无)"
+
+// Diff 逐字段比较两个指纹，报告密码套件顺序、扩展顺序、各扩展参数、GREASE位置和填充的差异，
+// 完全一致时返回空
+func Diff(a, b SpecFactory) ([]Difference, error) {
+	docA, err := ExportSpec(a)
+	if err != nil {
+		return nil, err
+	}
+	docB, err := ExportSpec(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	add := func(field, a, b string) {
+		if a != b {
+			diffs = append(diffs, Difference{Field: field, A: a, B: b})
+		}
+	}
+
+	add("tls_version_min", docA.TLSVersionMin, docB.TLSVersionMin)
+	add("tls_version_max", docA.TLSVersionMax, docB.TLSVersionMax)
+	add("compression_methods", fmt.Sprint(docA.CompressionMethods), fmt.Sprint(docB.CompressionMethods))
+
+	// 密码套件: GREASE位置与实际套件分开比较
+	add("cipher_suites.grease", greasePositions(docA.CipherSuites), greasePositions(docB.CipherSuites))
+	suitesA, suitesB := withoutGREASEName(docA.CipherSuites), withoutGREASEName(docB.CipherSuites)
+	diffs = append(diffs, diffList("cipher_suites", suitesA, suitesB)...)
+
+	// 扩展: GREASE和填充单独比较，其余扩展比较顺序和参数
+	extsA, extsB := extensionsByKey(docA.Extensions), extensionsByKey(docB.Extensions)
+	add("extensions.grease", extensionGREASEPositions(docA.Extensions), extensionGREASEPositions(docB.Extensions))
+	add("extensions.padding", paddingDescription(extsA), paddingDescription(extsB))
+
+	orderA, orderB := extensionOrder(docA.Extensions), extensionOrder(docB.Extensions)
+	diffs = append(diffs, diffList("extensions", orderA, orderB)...)
+
+	for _, key := range orderA {
+		extB, ok := extsB[key]
+		if !ok {
+			continue
+		}
+		diffs = append(diffs, diffExtension("extensions."+key, extsA[key], extB)...)
+	}
+	return diffs, nil
+}
+
+// diffList 比较两个有序列表: 先报告两侧独有的元素，元素相同时再报告顺序差异
+func diffList(field string, a, b []string) []Difference {
+	var diffs []Difference
+	var onlyA, onlyB []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			onlyA = append(onlyA, v)
+		}
+	}
+	for _, v := range b {
+		if !slices.Contains(a, v) {
+			onlyB = append(onlyB, v)
+		}
+	}
+	if len(onlyA) > 0 {
+		diffs = append(diffs, Difference{Field: field + ".only_a", A: strings.Join(onlyA, ","), B: absent})
+	}
+	if len(onlyB) > 0 {
+		diffs = append(diffs, Difference{Field: field + ".only_b", A: absent, B: strings.Join(onlyB, ",")})
+	}
+
+	commonA := slices.DeleteFunc(slices.Clone(a), func(v string) bool { return slices.Contains(onlyA, v) })
+	commonB := slices.DeleteFunc(slices.Clone(b), func(v string) bool { return slices.Contains(onlyB, v) })
+	if !slices.Equal(commonA, commonB) {
+		diffs = append(diffs, Difference{Field: field + ".order", A: strings.Join(commonA, ","), B: strings.Join(commonB, ",")})
+	}
+	return diffs
+}
+
+// diffExtension 按字段比较同一类型扩展的参数
+func diffExtension(prefix string, a, b ExtensionDocument) []Difference {
+	var diffs []Difference
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "type" || name == "id" {
+			continue
+		}
+		fa, fb := va.Field(i), vb.Field(i)
+		if reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			continue
+		}
+		diffs = append(diffs, Difference{Field: prefix + "." + name, A: formatField(fa), B: formatField(fb)})
+	}
+	return diffs
+}
+
+func formatField(v reflect.Value) string {
+	if v.IsZero() {
+		return absent
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, fmt.Sprintf("%+v", v.Index(i).Interface()))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// extensionKey 扩展的比较键，generic 扩展附带编号
+func extensionKey(ext ExtensionDocument) string {
+	if ext.Type == extTypeGeneric {
+		return fmt.Sprintf("%s(%d)", extTypeGeneric, ext.ID)
+	}
+	return ext.Type
+}
+
+func extensionsByKey(exts []ExtensionDocument) map[string]ExtensionDocument {
+	m := make(map[string]ExtensionDocument, len(exts))
+	for _, ext := range exts {
+		key := extensionKey(ext)
+		if _, ok := m[key]; !ok {
+			m[key] = ext
+		}
+	}
+	return m
+}
+
+// extensionOrder 返回除GREASE和填充外的扩展顺序
+func extensionOrder(exts []ExtensionDocument) []string {
+	order := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		if ext.Type == extTypeGREASE || ext.Type == extTypePadding {
+			continue
+		}
+		order = append(order, extensionKey(ext))
+	}
+	return order
+}
+
+func extensionGREASEPositions(exts []ExtensionDocument) string {
+	var positions []string
+	for i, ext := range exts {
+		if ext.Type == extTypeGREASE {
+			positions = append(positions, strconv.Itoa(i))
+		}
+	}
+	if len(positions) == 0 {
+		return absent
+	}
+	return strings.Join(positions, ",")
+}
+
+func greasePositions(values []string) string {
+	var positions []string
+	for i, v := range values {
+		if v == greaseName {
+			positions = append(positions, strconv.Itoa(i))
+		}
+	}
+	if len(positions) == 0 {
+		return absent
+	}
+	return strings.Join(positions, ",")
+}
+
+func withoutGREASEName(values []string) []string {
+	return slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == greaseName })
+}
+
+func paddingDescription(exts map[string]ExtensionDocument) string {
+	padding, ok := exts[extTypePadding]
+	if !ok {
+		return absent
+	}
+	if padding.Length > 0 {
+		return fmt.Sprintf("固定长度 %d", padding.Length)
+	}
+	return "BoringSSL"
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(spec *utls.ClientHelloSpec)
+		want   []Difference
+	}{
+		{
+			name:   "完全一致",
+			mutate: func(spec *utls.ClientHelloSpec) {},
+		},
+		{
+			name: "扩展顺序",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions[1], spec.Extensions[2] = spec.Extensions[2], spec.Extensions[1]
+			},
+			want: []Difference{{
+				Field: "extensions.order",
+				A:     "server_name,supported_groups,ec_point_formats,signature_algorithms,alpn,key_share,psk_key_exchange_modes,supported_versions",
+				B:     "supported_groups,server_name,ec_point_formats,signature_algorithms,alpn,key_share,psk_key_exchange_modes,supported_versions",
+			}},
+		},
+		{
+			name: "增加扩展",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions[:2], append([]utls.TLSExtension{&utls.ExtendedMasterSecretExtension{}}, spec.Extensions[2:]...)...)
+			},
+			// GREASE按扩展列表中的绝对位置比较，增删扩展后末尾GREASE的位置随之变化
+			want: []Difference{
+				{Field: "extensions.grease", A: "0,9", B: "0,10"},
+				{Field: "extensions.only_b", A: absent, B: "extended_master_secret"},
+			},
+		},
+		{
+			name:   "删除扩展",
+			mutate: removeExtension[*utls.SupportedPointsExtension],
+			want: []Difference{
+				{Field: "extensions.grease", A: "0,9", B: "0,8"},
+				{Field: "extensions.only_a", A: "ec_point_formats", B: absent},
+			},
+		},
+		{
+			name: "扩展参数",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				removeExtension[*utls.ALPNExtension](spec)
+				spec.Extensions = append(spec.Extensions[:5], append([]utls.TLSExtension{&utls.ALPNExtension{AlpnProtocols: []string{"http/1.1"}}}, spec.Extensions[5:]...)...)
+			},
+			want: []Difference{{Field: "extensions.alpn.protocols", A: "h2,http/1.1", B: "http/1.1"}},
+		},
+		{
+			name: "密码套件顺序",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.CipherSuites[1], spec.CipherSuites[2] = spec.CipherSuites[2], spec.CipherSuites[1]
+			},
+			want: []Difference{{
+				Field: "cipher_suites.order",
+				A:     "TLS_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+				B:     "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_AES_128_GCM_SHA256",
+			}},
+		},
+		{
+			name: "GREASE位置",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = spec.Extensions[:len(spec.Extensions)-1]
+			},
+			want: []Difference{{Field: "extensions.grease", A: "0,9", B: "0"}},
+		},
+		{
+			name: "填充",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions, &utls.UtlsPaddingExtension{GetPaddingLen: utls.BoringPaddingStyle})
+			},
+			want: []Difference{{Field: "extensions.padding", A: absent, B: "BoringSSL"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Diff(validSpec, func() *utls.ClientHelloSpec {
+				spec := validSpec()
+				tt.mutate(spec)
+				return spec
+			})
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if len(diffs) != len(tt.want) {
+				t.Fatalf("Diff() = %v, want %v", diffs, tt.want)
+			}
+			for i := range diffs {
+				if diffs[i] != tt.want[i] {
+					t.Errorf("Diff()[%d] = %v, want %v", i, diffs[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffProfiles(t *testing.T) {
+	chrome, _ := Profile("chrome_124")
+	firefox, _ := Profile("firefox_120")
+	diffs, err := Diff(chrome, firefox)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if len(diffs) == 0 {
+		t.Error("Diff() 不同浏览器的指纹应存在差异")
+	}
+}
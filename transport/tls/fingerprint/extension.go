@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+// TLS扩展类型编号，参见 IANA TLS ExtensionType Values
+const (
+	extServerName           uint16 = 0
+	extStatusRequest        uint16 = 5
+	extSupportedGroups      uint16 = 10
+	extECPointFormats       uint16 = 11
+	extSignatureAlgorithms  uint16 = 13
+	extALPN                 uint16 = 16
+	extStatusRequestV2      uint16 = 17
+	extSCT                  uint16 = 18
+	extPadding              uint16 = 21
+	extEncryptThenMAC       uint16 = 22
+	extExtendedMasterSecret uint16 = 23
+	extTokenBinding         uint16 = 24
+	extCompressCertificate  uint16 = 27
+	extRecordSizeLimit      uint16 = 28
+	extDelegatedCredentials uint16 = 34
+	extSessionTicket        uint16 = 35
+	extPreSharedKey         uint16 = 41
+	extEarlyData            uint16 = 42
+	extSupportedVersions    uint16 = 43
+	extCookie               uint16 = 44
+	extPSKKeyExchangeModes  uint16 = 45
+	extPostHandshakeAuth    uint16 = 49
+	extSignatureAlgsCert    uint16 = 50
+	extKeyShare             uint16 = 51
+	extQUICTransportParams  uint16 = 57
+	extNextProtoNeg         uint16 = 13172
+	extApplicationSettings  uint16 = 17513
+	extApplicationSettingsN uint16 = 17613
+	extChannelIDOld         uint16 = 30031
+	extChannelID            uint16 = 30032
+	extEncryptedClientHello uint16 = 65037
+	extRenegotiationInfo    uint16 = 65281
+)
+
+// isGREASE 判断是否为 RFC 8701 定义的GREASE值 (0x0a0a, 0x1a1a, ..., 0xfafa)
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	utls "github.com/refraction-networking/utls"
+	"github.com/refraction-networking/utls/dicttls"
+)
+
+// GetFirefox120ClientHelloSpec 返回 Firefox 120 的ClientHello规范
+func GetFirefox120ClientHelloSpec() *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		TLSVersMin: utls.VersionTLS12,
+		TLSVersMax: utls.VersionTLS13,
+		CipherSuites: []uint16{
+			utls.TLS_AES_128_GCM_SHA256,                  // 4865
+			utls.TLS_CHACHA20_POLY1305_SHA256,            // 4867
+			utls.TLS_AES_256_GCM_SHA384,                  // 4866
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // 49195
+			utls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,   // 49199
+			utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,  // 52393
+			utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,    // 52392
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, // 49196
+			utls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,   // 49200
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,    // 49162
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,    // 49161
+			utls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,      // 49171
+			utls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,      // 49172
+			utls.TLS_RSA_WITH_AES_128_GCM_SHA256,         // 156
+			utls.TLS_RSA_WITH_AES_256_GCM_SHA384,         // 157
+			utls.TLS_RSA_WITH_AES_128_CBC_SHA,            // 47
+			utls.TLS_RSA_WITH_AES_256_CBC_SHA,            // 53
+		},
+		CompressionMethods: []byte{0}, // 无压缩
+		Extensions: []utls.TLSExtension{
+			&utls.SNIExtension{},                  // 0
+			&utls.ExtendedMasterSecretExtension{}, // 23
+			&utls.RenegotiationInfoExtension{ // 65281
+				Renegotiation: utls.RenegotiateOnceAsClient},
+			&utls.SupportedCurvesExtension{ // 10
+				Curves: []utls.CurveID{
+					utls.X25519,    // 29
+					utls.CurveP256, // 23
+					utls.CurveP384, // 24
+					utls.CurveP521, // 25
+					256,            // ffdhe2048
+					257,            // ffdhe3072
+				}},
+			&utls.SupportedPointsExtension{ // 11
+				SupportedPoints: []byte{0}},
+			&utls.SessionTicketExtension{}, // 35
+			&utls.ALPNExtension{ // 16
+				AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.StatusRequestExtension{}, // 5
+			&utls.FakeDelegatedCredentialsExtension{ // 34
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.ECDSAWithP521AndSHA512,
+					utls.ECDSAWithSHA1,
+				}},
+			&utls.KeyShareExtension{ // 51
+				KeyShares: []utls.KeyShare{
+					{Group: utls.X25519},
+					{Group: utls.CurveP256},
+				}},
+			&utls.SupportedVersionsExtension{ // 43
+				Versions: []uint16{
+					utls.VersionTLS13,
+					utls.VersionTLS12}},
+			&utls.SignatureAlgorithmsExtension{ // 13
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.ECDSAWithP521AndSHA512,
+					utls.PSSWithSHA256,
+					utls.PSSWithSHA384,
+					utls.PSSWithSHA512,
+					utls.PKCS1WithSHA256,
+					utls.PKCS1WithSHA384,
+					utls.PKCS1WithSHA512,
+					utls.ECDSAWithSHA1,
+					utls.PKCS1WithSHA1,
+				}},
+			&utls.PSKKeyExchangeModesExtension{ // 45
+				Modes: []uint8{utls.PskModeDHE}},
+			&utls.FakeRecordSizeLimitExtension{ // 28
+				Limit: 0x4001},
+			&utls.GREASEEncryptedClientHelloExtension{ // 65037
+				CandidateCipherSuites: []utls.HPKESymmetricCipherSuite{
+					{KdfId: dicttls.HKDF_SHA256, AeadId: dicttls.AEAD_AES_128_GCM},
+					{KdfId: dicttls.HKDF_SHA256, AeadId: dicttls.AEAD_CHACHA20_POLY1305},
+				},
+				CandidatePayloadLens: []uint16{223}},
+		}}
+}
+
+// GetFirefox133ClientHelloSpec 返回 Firefox 133 的ClientHello规范
+// Firefox 132 起默认启用 X25519MLKEM768，并新增 SCT 与证书压缩扩展
+func GetFirefox133ClientHelloSpec() *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		TLSVersMin: utls.VersionTLS12,
+		TLSVersMax: utls.VersionTLS13,
+		CipherSuites: []uint16{
+			utls.TLS_AES_128_GCM_SHA256,                  // 4865
+			utls.TLS_CHACHA20_POLY1305_SHA256,            // 4867
+			utls.TLS_AES_256_GCM_SHA384,                  // 4866
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // 49195
+			utls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,   // 49199
+			utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,  // 52393
+			utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,    // 52392
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, // 49196
+			utls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,   // 49200
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,    // 49162
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,    // 49161
+			utls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,      // 49171
+			utls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,      // 49172
+			utls.TLS_RSA_WITH_AES_128_GCM_SHA256,         // 156
+			utls.TLS_RSA_WITH_AES_256_GCM_SHA384,         // 157
+			utls.TLS_RSA_WITH_AES_128_CBC_SHA,            // 47
+			utls.TLS_RSA_WITH_AES_256_CBC_SHA,            // 53
+		},
+		CompressionMethods: []byte{0}, // 无压缩
+		Extensions: []utls.TLSExtension{
+			&utls.SNIExtension{},                  // 0
+			&utls.ExtendedMasterSecretExtension{}, // 23
+			&utls.RenegotiationInfoExtension{ // 65281
+				Renegotiation: utls.RenegotiateOnceAsClient},
+			&utls.SupportedCurvesExtension{ // 10
+				Curves: []utls.CurveID{
+					utls.X25519MLKEM768, // 4588
+					utls.X25519,         // 29
+					utls.CurveP256,      // 23
+					utls.CurveP384,      // 24
+					utls.CurveP521,      // 25
+					256,                 // ffdhe2048
+					257,                 // ffdhe3072
+				}},
+			&utls.SupportedPointsExtension{ // 11
+				SupportedPoints: []byte{0}},
+			&utls.SessionTicketExtension{}, // 35
+			&utls.ALPNExtension{ // 16
+				AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.StatusRequestExtension{}, // 5
+			&utls.FakeDelegatedCredentialsExtension{ // 34
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.ECDSAWithP521AndSHA512,
+					utls.ECDSAWithSHA1,
+				}},
+			&utls.SCTExtension{}, // 18
+			&utls.KeyShareExtension{ // 51
+				KeyShares: []utls.KeyShare{
+					{Group: utls.X25519MLKEM768},
+					{Group: utls.X25519},
+					{Group: utls.CurveP256},
+				}},
+			&utls.SupportedVersionsExtension{ // 43
+				Versions: []uint16{
+					utls.VersionTLS13,
+					utls.VersionTLS12}},
+			&utls.SignatureAlgorithmsExtension{ // 13
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.ECDSAWithP521AndSHA512,
+					utls.PSSWithSHA256,
+					utls.PSSWithSHA384,
+					utls.PSSWithSHA512,
+					utls.PKCS1WithSHA256,
+					utls.PKCS1WithSHA384,
+					utls.PKCS1WithSHA512,
+					utls.ECDSAWithSHA1,
+					utls.PKCS1WithSHA1,
+				}},
+			&utls.PSKKeyExchangeModesExtension{ // 45
+				Modes: []uint8{utls.PskModeDHE}},
+			&utls.FakeRecordSizeLimitExtension{ // 28
+				Limit: 0x4001},
+			&utls.UtlsCompressCertExtension{ // 27
+				Algorithms: []utls.CertCompressionAlgo{
+					utls.CertCompressionZlib,
+					utls.CertCompressionBrotli,
+					utls.CertCompressionZstd,
+				}},
+			&utls.GREASEEncryptedClientHelloExtension{ // 65037
+				CandidateCipherSuites: []utls.HPKESymmetricCipherSuite{
+					{KdfId: dicttls.HKDF_SHA256, AeadId: dicttls.AEAD_AES_128_GCM},
+					{KdfId: dicttls.HKDF_SHA256, AeadId: dicttls.AEAD_CHACHA20_POLY1305},
+				},
+				CandidatePayloadLens: []uint16{223}},
+		}}
+}
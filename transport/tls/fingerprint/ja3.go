@@ -0,0 +1,441 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+var (
+	// ErrInvalidJA3 表示JA3字符串格式错误
+	ErrInvalidJA3 = errors.New("无效的JA3字符串")
+	// ErrUnsupportedExtension 表示扩展编号无法映射为 utls.TLSExtension
+	ErrUnsupportedExtension = errors.New("不支持的TLS扩展")
+)
+
+type ja3Options struct {
+	alpn                []string
+	alps                []string
+	signatureAlgorithms []utls.SignatureScheme
+	keyShareGroups      []utls.CurveID
+	certCompression     []utls.CertCompressionAlgo
+	grease              bool
+}
+
+// JA3Option 用于补充JA3字符串中不包含的扩展参数
+type JA3Option func(*ja3Options)
+
+func defaultJA3Options() *ja3Options {
+	return &ja3Options{
+		alpn: []string{"h2", "http/1.1"},
+		alps: []string{"h2"},
+		signatureAlgorithms: []utls.SignatureScheme{
+			utls.ECDSAWithP256AndSHA256,
+			utls.PSSWithSHA256,
+			utls.PKCS1WithSHA256,
+			utls.ECDSAWithP384AndSHA384,
+			utls.PSSWithSHA384,
+			utls.PKCS1WithSHA384,
+			utls.PSSWithSHA512,
+			utls.PKCS1WithSHA512,
+		},
+		certCompression: []utls.CertCompressionAlgo{utls.CertCompressionBrotli},
+	}
+}
+
+// WithJA3ALPN 设置ALPN扩展 (16) 的协议列表，默认为 h2, http/1.1
+func WithJA3ALPN(protocols ...string) JA3Option {
+	return func(o *ja3Options) {
+		o.alpn = protocols
+	}
+}
+
+// WithJA3ALPS 设置ALPS扩展 (17513/17613) 的协议列表，默认为 h2
+func WithJA3ALPS(protocols ...string) JA3Option {
+	return func(o *ja3Options) {
+		o.alps = protocols
+	}
+}
+
+// WithJA3SignatureAlgorithms 设置签名算法扩展 (13/50) 的算法列表，默认与 Chrome 一致
+func WithJA3SignatureAlgorithms(schemes ...utls.SignatureScheme) JA3Option {
+	return func(o *ja3Options) {
+		o.signatureAlgorithms = schemes
+	}
+}
+
+// WithJA3KeyShares 设置密钥共享扩展 (51) 中携带的曲线，
+// 默认从JA3的曲线列表中选取混合后量子曲线和 X25519，没有时选取第一条曲线
+func WithJA3KeyShares(groups ...utls.CurveID) JA3Option {
+	return func(o *ja3Options) {
+		o.keyShareGroups = groups
+	}
+}
+
+// WithJA3CertCompression 设置证书压缩扩展 (27) 的算法列表，默认为 brotli
+func WithJA3CertCompression(algos ...utls.CertCompressionAlgo) JA3Option {
+	return func(o *ja3Options) {
+		o.certCompression = algos
+	}
+}
+
+// WithJA3GREASE 按 Chrome 的方式补回GREASE: 密码套件、supported_groups、key_share、supported_versions 的首位，
+// 扩展列表的首位以及 padding/pre_shared_key 之前的末位。JA3中已经带有GREASE的字段保持不变
+func WithJA3GREASE() JA3Option {
+	return func(o *ja3Options) {
+		o.grease = true
+	}
+}
+
+// ja3Spec 解析后的JA3字段
+type ja3Spec struct {
+	version      uint16
+	ciphers      []uint16
+	extensions   []uint16
+	curves       []utls.CurveID
+	pointFormats []uint8
+}
+
+// FromJA3 将JA3字符串 (SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats)
+// 转换为 SpecFactory。JA3中不包含的参数 (签名算法、密钥共享等) 使用默认值，可通过 opts 覆盖。
+// 无法映射的扩展编号会以 ErrUnsupportedExtension 返回，不会被静默丢弃。
+//
+// 标准JA3计算时剔除了GREASE值，由这类JA3生成的 Chrome 指纹不会发送GREASE，容易与真实浏览器区分，
+// 需要时使用 WithJA3GREASE 按 Chrome 的位置补回。
+func FromJA3(ja3 string, opts ...JA3Option) (SpecFactory, error) {
+	options := defaultJA3Options()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	parsed, err := parseJA3(ja3)
+	if err != nil {
+		return nil, err
+	}
+	if options.grease {
+		parsed.insertGREASE()
+	}
+
+	// 预先构造一次，确保所有扩展都能被映射
+	if _, err := parsed.build(options); err != nil {
+		return nil, err
+	}
+
+	sf := func() *utls.ClientHelloSpec {
+		spec, _ := parsed.build(options)
+		return spec
+	}
+	// 能映射但 utls 无法生成的组合 (如 pre_shared_key 不在最后) 在导入时报错，而不是在握手时 panic
+	if _, err := marshalClientHello(sf, computeServerName); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJA3, err)
+	}
+	return sf, nil
+}
+
+func parseJA3(ja3 string) (*ja3Spec, error) {
+	fields := strings.Split(strings.TrimSpace(ja3), ",")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: 需要5个字段，实际为%d个", ErrInvalidJA3, len(fields))
+	}
+
+	version, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无效的版本号 %q", ErrInvalidJA3, fields[0])
+	}
+
+	ciphers, err := parseJA3List(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: 密码套件 %v", ErrInvalidJA3, err)
+	}
+	if len(ciphers) == 0 {
+		return nil, fmt.Errorf("%w: 密码套件列表为空", ErrInvalidJA3)
+	}
+	extensions, err := parseJA3List(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: 扩展 %v", ErrInvalidJA3, err)
+	}
+	curves, err := parseJA3List(fields[3])
+	if err != nil {
+		return nil, fmt.Errorf("%w: 曲线 %v", ErrInvalidJA3, err)
+	}
+	points, err := parseJA3List(fields[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: 点格式 %v", ErrInvalidJA3, err)
+	}
+
+	spec := &ja3Spec{
+		version:    uint16(version),
+		ciphers:    ciphers,
+		extensions: extensions,
+	}
+	for _, c := range curves {
+		spec.curves = append(spec.curves, utls.CurveID(c))
+	}
+	for _, p := range points {
+		if p > 0xff {
+			return nil, fmt.Errorf("%w: 无效的点格式 %d", ErrInvalidJA3, p)
+		}
+		spec.pointFormats = append(spec.pointFormats, uint8(p))
+	}
+	return spec, nil
+}
+
+// insertGREASE 在没有GREASE的字段中按 Chrome 的位置插入GREASE占位值
+func (s *ja3Spec) insertGREASE() {
+	const grease = 0x0a0a
+	if !slices.ContainsFunc(s.ciphers, isGREASE) {
+		s.ciphers = slices.Insert(s.ciphers, 0, grease)
+	}
+	if len(s.curves) > 0 && !slices.ContainsFunc(s.curves, func(c utls.CurveID) bool { return isGREASE(uint16(c)) }) {
+		s.curves = slices.Insert(s.curves, 0, grease)
+	}
+	if slices.ContainsFunc(s.extensions, isGREASE) {
+		return
+	}
+	// 末位GREASE位于 padding 与 pre_shared_key 之前
+	last := len(s.extensions)
+	for last > 0 && (s.extensions[last-1] == extPadding || s.extensions[last-1] == extPreSharedKey) {
+		last--
+	}
+	s.extensions = slices.Insert(s.extensions, last, grease)
+	s.extensions = slices.Insert(s.extensions, 0, grease)
+}
+
+func parseJA3List(field string) ([]uint16, error) {
+	if field == "" {
+		return nil, nil
+	}
+	parts := strings.Split(field, "-")
+	values := make([]uint16, 0, len(parts))
+	for _, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("无效的数值 %q", part)
+		}
+		values = append(values, uint16(v))
+	}
+	return values, nil
+}
+
+// build 按JA3字段构造一个新的 ClientHelloSpec，每次调用返回独立的扩展实例
+func (s *ja3Spec) build(o *ja3Options) (*utls.ClientHelloSpec, error) {
+	spec := &utls.ClientHelloSpec{
+		TLSVersMin:         utls.VersionTLS10,
+		TLSVersMax:         s.version,
+		CompressionMethods: []byte{0}, // 无压缩
+	}
+
+	hasGREASE := false
+	for _, c := range s.ciphers {
+		if isGREASE(c) {
+			hasGREASE = true
+			spec.CipherSuites = append(spec.CipherSuites, utls.GREASE_PLACEHOLDER)
+		} else {
+			spec.CipherSuites = append(spec.CipherSuites, c)
+		}
+	}
+
+	var unsupported []string
+	for _, id := range s.extensions {
+		ext, err := s.extension(id, o, hasGREASE)
+		if err != nil {
+			unsupported = append(unsupported, err.Error())
+			continue
+		}
+		spec.Extensions = append(spec.Extensions, ext)
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedExtension, strings.Join(unsupported, "; "))
+	}
+
+	for _, ext := range spec.Extensions {
+		if sv, ok := ext.(*utls.SupportedVersionsExtension); ok {
+			spec.TLSVersMax = utls.VersionTLS13
+			spec.TLSVersMin = sv.Versions[len(sv.Versions)-1]
+		}
+	}
+	return spec, nil
+}
+
+func (s *ja3Spec) extension(id uint16, o *ja3Options, grease bool) (utls.TLSExtension, error) {
+	if isGREASE(id) {
+		return &utls.UtlsGREASEExtension{}, nil
+	}
+
+	switch id {
+	case extServerName:
+		return &utls.SNIExtension{}, nil
+	case extStatusRequest:
+		return &utls.StatusRequestExtension{}, nil
+	case extSupportedGroups:
+		curves := make([]utls.CurveID, 0, len(s.curves))
+		for _, c := range s.curves {
+			if isGREASE(uint16(c)) {
+				c = utls.GREASE_PLACEHOLDER
+			}
+			curves = append(curves, c)
+		}
+		return &utls.SupportedCurvesExtension{Curves: curves}, nil
+	case extECPointFormats:
+		points := make([]uint8, len(s.pointFormats))
+		copy(points, s.pointFormats)
+		return &utls.SupportedPointsExtension{SupportedPoints: points}, nil
+	case extSignatureAlgorithms:
+		return &utls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: cloneSchemes(o.signatureAlgorithms)}, nil
+	case extALPN:
+		return &utls.ALPNExtension{AlpnProtocols: cloneStrings(o.alpn)}, nil
+	case extStatusRequestV2:
+		return &utls.StatusRequestV2Extension{}, nil
+	case extSCT:
+		return &utls.SCTExtension{}, nil
+	case extPadding:
+		return &utls.UtlsPaddingExtension{GetPaddingLen: utls.BoringPaddingStyle}, nil
+	case extEncryptThenMAC, extPostHandshakeAuth, extEarlyData:
+		// 客户端发送时扩展体为空
+		return &utls.GenericExtension{Id: id}, nil
+	case extExtendedMasterSecret:
+		return &utls.ExtendedMasterSecretExtension{}, nil
+	case extTokenBinding:
+		return &utls.FakeTokenBindingExtension{MajorVersion: 1, MinorVersion: 0, KeyParameters: []uint8{2}}, nil
+	case extCompressCertificate:
+		algos := make([]utls.CertCompressionAlgo, len(o.certCompression))
+		copy(algos, o.certCompression)
+		return &utls.UtlsCompressCertExtension{Algorithms: algos}, nil
+	case extRecordSizeLimit:
+		return &utls.FakeRecordSizeLimitExtension{Limit: 0x4001}, nil
+	case extDelegatedCredentials:
+		return &utls.FakeDelegatedCredentialsExtension{
+			SupportedSignatureAlgorithms: []utls.SignatureScheme{
+				utls.ECDSAWithP256AndSHA256,
+				utls.ECDSAWithP384AndSHA384,
+				utls.ECDSAWithP521AndSHA512,
+				utls.ECDSAWithSHA1,
+			}}, nil
+	case extSessionTicket:
+		return &utls.SessionTicketExtension{}, nil
+	case extPreSharedKey:
+		return defaultFakePSK(), nil
+	case extSupportedVersions:
+		return &utls.SupportedVersionsExtension{Versions: s.supportedVersions(grease)}, nil
+	case extPSKKeyExchangeModes:
+		return &utls.PSKKeyExchangeModesExtension{Modes: []uint8{utls.PskModeDHE}}, nil
+	case extSignatureAlgsCert:
+		return &utls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: cloneSchemes(o.signatureAlgorithms)}, nil
+	case extKeyShare:
+		return &utls.KeyShareExtension{KeyShares: s.keyShares(o)}, nil
+	case extNextProtoNeg:
+		return &utls.NPNExtension{}, nil
+	case extApplicationSettings:
+		return &utls.ApplicationSettingsExtension{SupportedProtocols: cloneStrings(o.alps)}, nil
+	case extChannelIDOld:
+		return &utls.FakeChannelIDExtension{OldExtensionID: true}, nil
+	case extChannelID:
+		return &utls.FakeChannelIDExtension{}, nil
+	case extEncryptedClientHello:
+		return utls.BoringGREASEECH(), nil
+	case extRenegotiationInfo:
+		return &utls.RenegotiationInfoExtension{Renegotiation: utls.RenegotiateOnceAsClient}, nil
+	case extCookie:
+		return nil, fmt.Errorf("%d (cookie 仅在HelloRetryRequest之后发送)", id)
+	case extQUICTransportParams:
+		return nil, fmt.Errorf("%d (QUIC传输参数不适用于TCP上的TLS)", id)
+	case extApplicationSettingsN:
+		return &utls.ApplicationSettingsExtensionNew{SupportedProtocols: cloneStrings(o.alps)}, nil
+	default:
+		return nil, fmt.Errorf("%d", id)
+	}
+}
+
+// supportedVersions 根据JA3版本号和密码套件推断 supported_versions 扩展内容
+func (s *ja3Spec) supportedVersions(grease bool) []uint16 {
+	var versions []uint16
+	if grease {
+		versions = append(versions, utls.GREASE_PLACEHOLDER)
+	}
+	for _, c := range s.ciphers {
+		// TLS 1.3 密码套件位于 0x1301-0x1305
+		if c >= 0x1301 && c <= 0x1305 {
+			versions = append(versions, utls.VersionTLS13)
+			break
+		}
+	}
+	for v := s.version; v >= utls.VersionTLS12; v-- {
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 || versions[len(versions)-1] == utls.GREASE_PLACEHOLDER {
+		versions = append(versions, s.version)
+	}
+	return versions
+}
+
+// keyShares 返回密钥共享扩展中的曲线，未指定时参照浏览器行为从曲线列表中选取
+func (s *ja3Spec) keyShares(o *ja3Options) []utls.KeyShare {
+	var shares []utls.KeyShare
+	hasGREASE := false
+	for _, c := range s.curves {
+		if isGREASE(uint16(c)) {
+			hasGREASE = true
+			break
+		}
+	}
+	if hasGREASE {
+		shares = append(shares, utls.KeyShare{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}})
+	}
+
+	if len(o.keyShareGroups) > 0 {
+		for _, g := range o.keyShareGroups {
+			shares = append(shares, utls.KeyShare{Group: g})
+		}
+		return shares
+	}
+
+	var classical utls.CurveID
+	for _, c := range s.curves {
+		switch {
+		case isGREASE(uint16(c)):
+		case c == utls.X25519MLKEM768 || c == utls.X25519Kyber768Draft00:
+			shares = append(shares, utls.KeyShare{Group: c})
+		case c == utls.X25519:
+			classical = c
+		case classical == 0 && (c == utls.CurveP256 || c == utls.CurveP384 || c == utls.CurveP521):
+			classical = c
+		}
+	}
+	if classical != 0 {
+		shares = append(shares, utls.KeyShare{Group: classical})
+	}
+	return shares
+}
+
+func cloneStrings(s []string) []string {
+	out := make([]string, len(s))
+	copy(out, s)
+	return out
+}
+
+func cloneSchemes(s []utls.SignatureScheme) []utls.SignatureScheme {
+	out := make([]utls.SignatureScheme, len(s))
+	copy(out, s)
+	return out
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestFromJA3Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		ja3  string
+		want error
+	}{
+		{"字段数量不足", "771,4865,0-10,29", ErrInvalidJA3},
+		{"无效的版本号", "tls13,4865,0-10-11,29,0", ErrInvalidJA3},
+		{"空的密码套件", "771,,0-10-11,29,0", ErrInvalidJA3},
+		{"无效的数值", "771,4865-abc,0-10-11,29,0", ErrInvalidJA3},
+		{"点格式超出范围", "771,4865,0-10-11,29,256", ErrInvalidJA3},
+		{"未知扩展", "771,4865,0-10-11-9999,29,0", ErrUnsupportedExtension},
+		{"cookie扩展", "771,4865,0-10-11-44,29,0", ErrUnsupportedExtension},
+		{"QUIC传输参数", "771,4865,0-10-11-57,29,0", ErrUnsupportedExtension},
+		// pre_shared_key (41) 后面还有 ALPN (16)，utls 应用预设时会直接 panic
+		{"pre_shared_key不在末尾", "771,4865-4866-4867-49195,0-10-11-13-43-45-51-41-16,29-23,0", ErrInvalidJA3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := FromJA3(tt.ja3)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("FromJA3() error = %v, want %v", err, tt.want)
+			}
+			if sf != nil {
+				t.Error("FromJA3() 返回错误时仍返回了 SpecFactory")
+			}
+		})
+	}
+}
+
+func TestFromJA3Extensions(t *testing.T) {
+	tests := []struct {
+		name  string
+		ja3   string
+		opts  []JA3Option
+		ext   int // 被检查的扩展在JA3中的位置
+		check func(t *testing.T, ext utls.TLSExtension)
+	}{
+		{
+			name: "新版ALPS编号",
+			ja3:  "771,4865-4866-4867,0-10-11-13-16-43-45-51-17613,29-23,0",
+			opts: []JA3Option{WithJA3ALPS("h2")},
+			ext:  8,
+			check: func(t *testing.T, ext utls.TLSExtension) {
+				alps, ok := ext.(*utls.ApplicationSettingsExtensionNew)
+				if !ok {
+					t.Fatalf("扩展 17613 映射为 %T, want *utls.ApplicationSettingsExtensionNew", ext)
+				}
+				if !slices.Equal(alps.SupportedProtocols, []string{"h2"}) {
+					t.Errorf("ALPS协议 = %v, want [h2]", alps.SupportedProtocols)
+				}
+			},
+		},
+		{
+			name: "旧版ALPS编号",
+			ja3:  "771,4865-4866-4867,0-10-11-13-16-43-45-51-17513,29-23,0",
+			ext:  8,
+			check: func(t *testing.T, ext utls.TLSExtension) {
+				if _, ok := ext.(*utls.ApplicationSettingsExtension); !ok {
+					t.Fatalf("扩展 17513 映射为 %T, want *utls.ApplicationSettingsExtension", ext)
+				}
+			},
+		},
+		{
+			name: "pre_shared_key在末尾",
+			ja3:  "771,4865-4866-4867-49195,0-10-11-13-43-45-51-16-41,29-23,0",
+			ext:  8,
+			check: func(t *testing.T, ext utls.TLSExtension) {
+				if _, ok := ext.(utls.PreSharedKeyExtension); !ok {
+					t.Fatalf("扩展 41 映射为 %T, want utls.PreSharedKeyExtension", ext)
+				}
+			},
+		},
+		{
+			name: "曲线按JA3原样保留",
+			ja3:  "771,4865-49195,0-10-11-13-43-51,29-23-24,0-1-2",
+			ext:  1,
+			check: func(t *testing.T, ext utls.TLSExtension) {
+				curves := ext.(*utls.SupportedCurvesExtension).Curves
+				if want := []utls.CurveID{utls.X25519, utls.CurveP256, utls.CurveP384}; !slices.Equal(curves, want) {
+					t.Errorf("supported_groups = %v, want %v", curves, want)
+				}
+			},
+		},
+		{
+			name: "点格式",
+			ja3:  "771,4865-49195,0-10-11-13-43-51,29-23-24,0-1-2",
+			ext:  2,
+			check: func(t *testing.T, ext utls.TLSExtension) {
+				points := ext.(*utls.SupportedPointsExtension).SupportedPoints
+				if want := []uint8{0, 1, 2}; !slices.Equal(points, want) {
+					t.Errorf("ec_point_formats = %v, want %v", points, want)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := FromJA3(tt.ja3, tt.opts...)
+			if err != nil {
+				t.Fatalf("FromJA3() error = %v", err)
+			}
+			tt.check(t, sf().Extensions[tt.ext])
+
+			fp, err := Compute(sf)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if fp.JA3 != tt.ja3 {
+				t.Errorf("JA3 = %s, want %s", fp.JA3, tt.ja3)
+			}
+		})
+	}
+}
+
+func TestFromJA3KeyShareDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		curves string
+		opts   []JA3Option
+		want   []utls.CurveID
+	}{
+		{"X25519优先", "29-23-24", nil, []utls.CurveID{utls.X25519}},
+		{"没有X25519时取第一条经典曲线", "24-23", nil, []utls.CurveID{utls.CurveP384}},
+		{"混合后量子曲线与X25519", "4588-29-23-24", nil, []utls.CurveID{utls.X25519MLKEM768, utls.X25519}},
+		{"Kyber草案与X25519", "25497-29-23-24", nil, []utls.CurveID{utls.X25519Kyber768Draft00, utls.X25519}},
+		{"显式指定", "29-23-24", []JA3Option{WithJA3KeyShares(utls.CurveP256)}, []utls.CurveID{utls.CurveP256}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := FromJA3("771,4865-49195,0-10-11-13-43-51,"+tt.curves+",0", tt.opts...)
+			if err != nil {
+				t.Fatalf("FromJA3() error = %v", err)
+			}
+			var got []utls.CurveID
+			for _, ks := range sf().Extensions[5].(*utls.KeyShareExtension).KeyShares {
+				got = append(got, ks.Group)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("key_share = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromJA3ProfileRoundTrip(t *testing.T) {
+	for _, name := range ProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			profile, _ := Profile(name)
+			want, err := Compute(profile)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+
+			sf, err := FromJA3(want.JA3)
+			if err != nil {
+				t.Fatalf("FromJA3(%s) error = %v", want.JA3, err)
+			}
+			got, err := Compute(sf)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if got.JA3 != want.JA3 {
+				t.Errorf("JA3 = %s, want %s", got.JA3, want.JA3)
+			}
+		})
+	}
+}
+
+func TestFromJA3GREASE(t *testing.T) {
+	profile, _ := Profile("chrome_124")
+	want, err := Compute(profile)
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+
+	plain, err := FromJA3(want.JA3)
+	if err != nil {
+		t.Fatalf("FromJA3() error = %v", err)
+	}
+	if spec := plain(); isGREASE(spec.CipherSuites[0]) || hasGREASEExtension(spec) {
+		t.Error("默认不应插入GREASE")
+	}
+
+	sf, err := FromJA3(want.JA3, WithJA3GREASE())
+	if err != nil {
+		t.Fatalf("FromJA3() error = %v", err)
+	}
+	spec := sf()
+	if spec.CipherSuites[0] != utls.GREASE_PLACEHOLDER {
+		t.Errorf("首个密码套件 = %#04x, want GREASE", spec.CipherSuites[0])
+	}
+	if _, ok := spec.Extensions[0].(*utls.UtlsGREASEExtension); !ok {
+		t.Errorf("首个扩展 = %T, want GREASE", spec.Extensions[0])
+	}
+	if _, ok := spec.Extensions[len(spec.Extensions)-1].(*utls.UtlsGREASEExtension); !ok {
+		t.Errorf("末尾扩展 = %T, want GREASE", spec.Extensions[len(spec.Extensions)-1])
+	}
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedCurvesExtension:
+			if e.Curves[0] != utls.GREASE_PLACEHOLDER {
+				t.Errorf("supported_groups 首位 = %v, want GREASE", e.Curves[0])
+			}
+		case *utls.KeyShareExtension:
+			if e.KeyShares[0].Group != utls.GREASE_PLACEHOLDER {
+				t.Errorf("key_share 首位 = %v, want GREASE", e.KeyShares[0].Group)
+			}
+		case *utls.SupportedVersionsExtension:
+			if e.Versions[0] != utls.GREASE_PLACEHOLDER {
+				t.Errorf("supported_versions 首位 = %#04x, want GREASE", e.Versions[0])
+			}
+		}
+	}
+
+	// GREASE 不参与JA3/JA4计算，补回后指纹保持不变
+	got, err := Compute(sf)
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if got.JA3 != want.JA3 || got.JA4 != want.JA4 {
+		t.Errorf("补回GREASE后指纹 = %s / %s, want %s / %s", got.JA3, got.JA4, want.JA3, want.JA4)
+	}
+}
+
+func TestFromJA3GREASEBeforePadding(t *testing.T) {
+	sf, err := FromJA3("771,4865-4866-4867,0-10-11-13-43-45-51-21-41,29-23,0", WithJA3GREASE())
+	if err != nil {
+		t.Fatalf("FromJA3() error = %v", err)
+	}
+	exts := sf().Extensions
+	n := len(exts)
+	if _, ok := exts[n-3].(*utls.UtlsGREASEExtension); !ok {
+		t.Errorf("倒数第三个扩展 = %T, want GREASE", exts[n-3])
+	}
+	if _, ok := exts[n-2].(*utls.UtlsPaddingExtension); !ok {
+		t.Errorf("倒数第二个扩展 = %T, want padding", exts[n-2])
+	}
+	if _, ok := exts[n-1].(utls.PreSharedKeyExtension); !ok {
+		t.Errorf("末尾扩展 = %T, want pre_shared_key", exts[n-1])
+	}
+}
+
+func hasGREASEExtension(spec *utls.ClientHelloSpec) bool {
+	for _, ext := range spec.Extensions {
+		if _, ok := ext.(*utls.UtlsGREASEExtension); ok {
+			return true
+		}
+	}
+	return false
+}
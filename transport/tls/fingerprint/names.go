@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+	"github.com/refraction-networking/utls/dicttls"
+)
+
+// greaseName 声明式描述中GREASE占位符的名称，实际值在每次握手时随机生成
+const greaseName = "GREASE"
+
+// extraGroupNames 补充 dicttls 中缺少的混合后量子曲线名称
+var extraGroupNames = map[uint16]string{
+	uint16(utls.X25519Kyber768Draft00): "X25519Kyber768Draft00",
+	uint16(utls.X25519MLKEM768):        "X25519MLKEM768",
+}
+
+var versionNames = map[uint16]string{
+	utls.VersionTLS10: "1.0",
+	utls.VersionTLS11: "1.1",
+	utls.VersionTLS12: "1.2",
+	utls.VersionTLS13: "1.3",
+}
+
+var certCompressionNames = map[uint16]string{
+	uint16(utls.CertCompressionZlib):   "zlib",
+	uint16(utls.CertCompressionBrotli): "brotli",
+	uint16(utls.CertCompressionZstd):   "zstd",
+}
+
+// formatValue 按名称表输出数值，GREASE值输出为占位符，未知值输出为十六进制
+func formatValue(v uint16, names map[uint16]string) string {
+	if isGREASE(v) {
+		return greaseName
+	}
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// parseValue 解析名称或数值 (十进制或0x前缀的十六进制)，GREASE占位符解析为 utls.GREASE_PLACEHOLDER
+func parseValue(s string, names map[uint16]string) (uint16, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, greaseName) {
+		return utls.GREASE_PLACEHOLDER, nil
+	}
+	for v, name := range names {
+		if strings.EqualFold(name, s) {
+			return v, nil
+		}
+	}
+	v, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("未知的取值 %q", s)
+	}
+	return uint16(v), nil
+}
+
+func formatValues(values []uint16, names map[uint16]string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, formatValue(v, names))
+	}
+	return out
+}
+
+func parseValues(values []string, names map[uint16]string) ([]uint16, error) {
+	out := make([]uint16, 0, len(values))
+	for _, s := range values {
+		v, err := parseValue(s, names)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+var (
+	cipherSuiteNames     = dicttls.DictCipherSuiteValueIndexed
+	signatureSchemeNames = dicttls.DictSignatureSchemeValueIndexed
+	extensionNames       = dicttls.DictExtTypeValueIndexed
+	groupNames           = mergeNames(dicttls.DictSupportedGroupsValueIndexed, extraGroupNames)
+)
+
+func mergeNames(tables ...map[uint16]string) map[uint16]string {
+	names := make(map[uint16]string)
+	for _, table := range tables {
+		for v, name := range table {
+			names[v] = name
+		}
+	}
+	return names
+}
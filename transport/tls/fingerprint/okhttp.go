@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	utls "github.com/refraction-networking/utls"
+)
+
+// GetOkHttp4ClientHelloSpec 返回 OkHttp 4.x (Android 11 系统 Conscrypt) 的ClientHello规范。
+// OkHttp 默认的 MODERN_TLS 在 Android 10+ 上启用 TLS 1.3，并通过ALPN协商 h2；Conscrypt 默认不发送GREASE。
+// 对应 JA3: 771,4865-4866-4867-49195-49196-52393-49199-49200-52392-49171-49172-156-157-47-53,
+// 0-23-65281-10-11-35-16-5-13-51-45-43-21,29-23-24,0
+func GetOkHttp4ClientHelloSpec() *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		TLSVersMin: utls.VersionTLS12,
+		TLSVersMax: utls.VersionTLS13,
+		CipherSuites: []uint16{
+			utls.TLS_AES_128_GCM_SHA256,                  // 4865
+			utls.TLS_AES_256_GCM_SHA384,                  // 4866
+			utls.TLS_CHACHA20_POLY1305_SHA256,            // 4867
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, // 49195
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, // 49196
+			utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,  // 52393
+			utls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,   // 49199
+			utls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,   // 49200
+			utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,    // 52392
+			utls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,      // 49171
+			utls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,      // 49172
+			utls.TLS_RSA_WITH_AES_128_GCM_SHA256,         // 156
+			utls.TLS_RSA_WITH_AES_256_GCM_SHA384,         // 157
+			utls.TLS_RSA_WITH_AES_128_CBC_SHA,            // 47
+			utls.TLS_RSA_WITH_AES_256_CBC_SHA,            // 53
+		},
+		CompressionMethods: []byte{0}, // 无压缩
+		Extensions: []utls.TLSExtension{
+			&utls.SNIExtension{},                  // 0
+			&utls.ExtendedMasterSecretExtension{}, // 23
+			&utls.RenegotiationInfoExtension{ // 65281
+				Renegotiation: utls.RenegotiateOnceAsClient},
+			&utls.SupportedCurvesExtension{ // 10
+				Curves: []utls.CurveID{
+					utls.X25519,    // 29
+					utls.CurveP256, // 23
+					utls.CurveP384, // 24
+				}},
+			&utls.SupportedPointsExtension{ // 11
+				SupportedPoints: []byte{0}},
+			&utls.SessionTicketExtension{}, // 35
+			&utls.ALPNExtension{ // 16
+				AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.StatusRequestExtension{}, // 5
+			&utls.SignatureAlgorithmsExtension{ // 13
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.PSSWithSHA256,
+					utls.PKCS1WithSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.PSSWithSHA384,
+					utls.PKCS1WithSHA384,
+					utls.PSSWithSHA512,
+					utls.PKCS1WithSHA512,
+					utls.PKCS1WithSHA1,
+				}},
+			&utls.KeyShareExtension{ // 51
+				KeyShares: []utls.KeyShare{
+					{Group: utls.X25519},
+				}},
+			&utls.PSKKeyExchangeModesExtension{ // 45
+				Modes: []uint8{utls.PskModeDHE}},
+			&utls.SupportedVersionsExtension{ // 43
+				Versions: []uint16{
+					utls.VersionTLS13,
+					utls.VersionTLS12}},
+			&utls.UtlsPaddingExtension{ // 21
+				GetPaddingLen: utls.BoringPaddingStyle},
+		}}
+}
@@ -0,0 +1,423 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrClientHelloNotFound 表示抓包文件中没有符合条件的ClientHello
+var ErrClientHelloNotFound = errors.New("抓包文件中未找到ClientHello")
+
+const (
+	pcapMagicMicro         = 0xa1b2c3d4
+	pcapMagicNano          = 0xa1b23c4d
+	pcapngBlockSHB         = 0x0a0d0d0a
+	pcapngBlockIDB         = 0x00000001
+	pcapngBlockOPB         = 0x00000002
+	pcapngBlockSPB         = 0x00000003
+	pcapngBlockEPB         = 0x00000006
+	pcapngByteOrderMagic   = 0x1a2b3c4d
+	pcapMaxBlockLen        = 64 << 20
+	pcapMaxPendingSegments = 64
+	linkTypeNull           = 0
+	linkTypeEthernet       = 1
+	linkTypeRaw            = 101
+	linkTypeLinuxSLL       = 113
+	linkTypeLoop           = 108
+	linkTypeIPv4           = 228
+	linkTypeIPv6           = 229
+	linkTypeLinuxSLL2      = 276
+	etherTypeIPv4          = 0x0800
+	etherTypeIPv6          = 0x86dd
+	etherTypeVLAN          = 0x8100
+	etherTypeQinQ          = 0x88a8
+	ipProtocolTCP          = 6
+	ipv6HeaderHopByHop     = 0
+	ipv6HeaderRouting      = 43
+	ipv6HeaderDestOptions  = 60
+)
+
+// FromPcap 从 pcap/pcapng 抓包文件中提取ClientHello并转换为 SpecFactory。
+// filter 为空时使用文件中第一个完整的ClientHello，否则使用SNI与 filter 相同的第一个ClientHello。
+// 跨多个TCP分段的ClientHello会按序列号重组。
+func FromPcap(path string, filter string, opts ...CaptureOption) (SpecFactory, error) {
+	hellos, err := readPcapClientHellos(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, raw := range hellos {
+		hello, err := parseClientHello(raw)
+		if err != nil {
+			continue
+		}
+		if filter != "" && hello.serverName != filter {
+			continue
+		}
+		return FromClientHelloBytes(raw, opts...)
+	}
+
+	if filter != "" {
+		return nil, fmt.Errorf("%w: SNI=%s", ErrClientHelloNotFound, filter)
+	}
+	return nil, ErrClientHelloNotFound
+}
+
+// readPcapClientHellos 读取抓包文件并返回其中所有完整的ClientHello记录
+func readPcapClientHellos(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开抓包文件失败: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("读取抓包文件头失败: %w", err)
+	}
+
+	asm := newHelloAssembler()
+	if binary.LittleEndian.Uint32(magic) == pcapngBlockSHB {
+		err = readPcapng(r, asm.addFrame)
+	} else {
+		err = readPcap(r, asm.addFrame)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asm.hellos, nil
+}
+
+// readPcap 解析 libpcap 格式
+func readPcap(r io.Reader, onFrame func(linkType uint32, frame []byte)) error {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("读取pcap文件头失败: %w", err)
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(header) == pcapMagicMicro || binary.LittleEndian.Uint32(header) == pcapMagicNano:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(header) == pcapMagicMicro || binary.BigEndian.Uint32(header) == pcapMagicNano:
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("无法识别的抓包文件格式")
+	}
+	linkType := order.Uint32(header[20:24]) & 0x0fffffff
+
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("读取pcap记录失败: %w", err)
+		}
+		capLen := order.Uint32(record[8:12])
+		if capLen > pcapMaxBlockLen {
+			return fmt.Errorf("pcap记录长度异常: %d", capLen)
+		}
+		frame := make([]byte, capLen)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			// 抓包被截断时保留已读取的内容
+			return nil
+		}
+		onFrame(linkType, frame)
+	}
+}
+
+// readPcapng 解析 pcapng 格式，支持多个接口和多个Section
+func readPcapng(r io.Reader, onFrame func(linkType uint32, frame []byte)) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	var linkTypes []uint32
+
+	head := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, head); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return fmt.Errorf("读取pcapng块失败: %w", err)
+		}
+
+		blockType := order.Uint32(head[0:4])
+		if binary.LittleEndian.Uint32(head[0:4]) == pcapngBlockSHB {
+			blockType = pcapngBlockSHB
+		}
+
+		var body []byte
+		if blockType == pcapngBlockSHB {
+			// Section头部块需要先读取字节序标记才能确定块长度的字节序
+			bom := make([]byte, 4)
+			if _, err := io.ReadFull(r, bom); err != nil {
+				return fmt.Errorf("读取pcapng字节序标记失败: %w", err)
+			}
+			switch {
+			case binary.LittleEndian.Uint32(bom) == pcapngByteOrderMagic:
+				order = binary.LittleEndian
+			case binary.BigEndian.Uint32(bom) == pcapngByteOrderMagic:
+				order = binary.BigEndian
+			default:
+				return fmt.Errorf("无效的pcapng字节序标记")
+			}
+			linkTypes = linkTypes[:0]
+			totalLen := order.Uint32(head[4:8])
+			if totalLen < 16 || totalLen > pcapMaxBlockLen {
+				return fmt.Errorf("pcapng块长度异常: %d", totalLen)
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(totalLen)-12); err != nil {
+				return nil
+			}
+			continue
+		}
+
+		totalLen := order.Uint32(head[4:8])
+		if totalLen < 12 || totalLen > pcapMaxBlockLen {
+			return fmt.Errorf("pcapng块长度异常: %d", totalLen)
+		}
+		body = make([]byte, totalLen-8)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil
+		}
+		body = body[:len(body)-4] // 去掉尾部的块长度
+
+		switch blockType {
+		case pcapngBlockIDB:
+			if len(body) >= 2 {
+				linkTypes = append(linkTypes, uint32(order.Uint16(body[0:2])))
+			}
+		case pcapngBlockEPB:
+			if len(body) < 20 {
+				continue
+			}
+			ifaceID := order.Uint32(body[0:4])
+			capLen := order.Uint32(body[12:16])
+			if int(ifaceID) >= len(linkTypes) || int(capLen) > len(body)-20 {
+				continue
+			}
+			onFrame(linkTypes[ifaceID], body[20:20+capLen])
+		case pcapngBlockSPB:
+			if len(body) < 4 || len(linkTypes) == 0 {
+				continue
+			}
+			onFrame(linkTypes[0], body[4:])
+		case pcapngBlockOPB:
+			if len(body) < 20 {
+				continue
+			}
+			ifaceID := order.Uint16(body[0:2])
+			capLen := order.Uint32(body[12:16])
+			if int(ifaceID) >= len(linkTypes) || int(capLen) > len(body)-20 {
+				continue
+			}
+			onFrame(linkTypes[ifaceID], body[20:20+capLen])
+		}
+	}
+}
+
+// tcpFlow TCP四元组
+type tcpFlow struct {
+	src, dst         string
+	srcPort, dstPort uint16
+}
+
+// tcpStream 单个TCP流上正在重组的ClientHello
+type tcpStream struct {
+	started bool
+	buf     []byte
+	nextSeq uint32
+	pending map[uint32][]byte
+}
+
+// helloAssembler 从链路层帧中提取TCP载荷并重组ClientHello记录
+type helloAssembler struct {
+	streams map[tcpFlow]*tcpStream
+	hellos  [][]byte
+}
+
+func newHelloAssembler() *helloAssembler {
+	return &helloAssembler{streams: make(map[tcpFlow]*tcpStream)}
+}
+
+func (a *helloAssembler) addFrame(linkType uint32, frame []byte) {
+	packet, ok := stripLinkLayer(linkType, frame)
+	if !ok {
+		return
+	}
+	flow, seq, payload, ok := parseTCPPacket(packet)
+	if !ok || len(payload) == 0 {
+		return
+	}
+
+	stream, ok := a.streams[flow]
+	if !ok {
+		stream = &tcpStream{pending: make(map[uint32][]byte)}
+		a.streams[flow] = stream
+	}
+
+	if !stream.started {
+		// TLS握手记录: 类型0x16，版本0x03xx，握手类型0x01
+		if len(payload) >= 6 && payload[0] == recordTypeHandshake && payload[1] == 0x03 && payload[5] == handshakeTypeClientHello {
+			stream.started = true
+			stream.nextSeq = seq
+		} else if len(stream.pending) >= pcapMaxPendingSegments {
+			// 乱序到达的后续分段先缓存，超过上限说明该流不是TLS握手
+			stream.pending = make(map[uint32][]byte)
+		}
+	}
+
+	stream.pending[seq] = payload
+	if !stream.started {
+		return
+	}
+	for {
+		data, ok := stream.pending[stream.nextSeq]
+		if !ok {
+			break
+		}
+		delete(stream.pending, stream.nextSeq)
+		stream.buf = append(stream.buf, data...)
+		stream.nextSeq += uint32(len(data))
+	}
+
+	if len(stream.buf) >= 5 {
+		recordLen := 5 + int(binary.BigEndian.Uint16(stream.buf[3:5]))
+		if len(stream.buf) >= recordLen {
+			a.hellos = append(a.hellos, stream.buf[:recordLen])
+			delete(a.streams, flow)
+		}
+	}
+}
+
+// stripLinkLayer 去掉链路层头部，返回IP报文
+func stripLinkLayer(linkType uint32, frame []byte) ([]byte, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:14])
+		frame = frame[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(frame) < 4 {
+				return nil, false
+			}
+			etherType = binary.BigEndian.Uint16(frame[2:4])
+			frame = frame[4:]
+		}
+		if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+			return nil, false
+		}
+		return frame, true
+	case linkTypeNull, linkTypeLoop:
+		if len(frame) < 4 {
+			return nil, false
+		}
+		return frame[4:], true
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return nil, false
+		}
+		return frame[16:], true
+	case linkTypeLinuxSLL2:
+		if len(frame) < 20 {
+			return nil, false
+		}
+		return frame[20:], true
+	case linkTypeRaw, linkTypeIPv4, linkTypeIPv6:
+		return frame, true
+	default:
+		return nil, false
+	}
+}
+
+// parseTCPPacket 解析IPv4/IPv6上的TCP报文，返回四元组、序列号和载荷
+func parseTCPPacket(packet []byte) (tcpFlow, uint32, []byte, bool) {
+	var flow tcpFlow
+	if len(packet) < 1 {
+		return flow, 0, nil, false
+	}
+
+	var segment []byte
+	switch packet[0] >> 4 {
+	case 4:
+		if len(packet) < 20 {
+			return flow, 0, nil, false
+		}
+		ihl := int(packet[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(packet[2:4]))
+		// 忽略分片报文
+		if packet[9] != ipProtocolTCP || binary.BigEndian.Uint16(packet[6:8])&0x3fff != 0 {
+			return flow, 0, nil, false
+		}
+		if ihl < 20 || totalLen < ihl || totalLen > len(packet) {
+			return flow, 0, nil, false
+		}
+		flow.src = string(packet[12:16])
+		flow.dst = string(packet[16:20])
+		segment = packet[ihl:totalLen]
+	case 6:
+		if len(packet) < 40 {
+			return flow, 0, nil, false
+		}
+		payloadLen := int(binary.BigEndian.Uint16(packet[4:6]))
+		if 40+payloadLen > len(packet) {
+			return flow, 0, nil, false
+		}
+		next := packet[6]
+		flow.src = string(packet[8:24])
+		flow.dst = string(packet[24:40])
+		segment = packet[40 : 40+payloadLen]
+		for next == ipv6HeaderHopByHop || next == ipv6HeaderRouting || next == ipv6HeaderDestOptions {
+			if len(segment) < 8 {
+				return flow, 0, nil, false
+			}
+			extLen := (int(segment[1]) + 1) * 8
+			if extLen > len(segment) {
+				return flow, 0, nil, false
+			}
+			next = segment[0]
+			segment = segment[extLen:]
+		}
+		if next != ipProtocolTCP {
+			return flow, 0, nil, false
+		}
+	default:
+		return flow, 0, nil, false
+	}
+
+	if len(segment) < 20 {
+		return flow, 0, nil, false
+	}
+	dataOffset := int(segment[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(segment) {
+		return flow, 0, nil, false
+	}
+	flow.srcPort = binary.BigEndian.Uint16(segment[0:2])
+	flow.dstPort = binary.BigEndian.Uint16(segment[2:4])
+	seq := binary.BigEndian.Uint32(segment[4:8])
+	return flow, seq, segment[dataOffset:], true
+}
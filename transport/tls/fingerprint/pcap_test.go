@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata 中的抓包由 chrome_124 (SNI first.example) 与 firefox_120 (SNI second.example) 的ClientHello构造:
+//   - hello_le.pcap: 小端微秒格式，以太网+IPv4，包含一条非TLS流量和两个ClientHello
+//   - hello_be.pcap: 大端纳秒格式，原始IPv6，包含两个ClientHello
+//   - hello.pcapng: 与 hello_le.pcap 内容相同的 pcapng 文件
+//   - hello_split.pcap: first.example 的ClientHello被拆分为三个TCP分段
+//   - hello_reordered.pcap: 同上，分段按 3、1、1(重传)、2 的顺序到达
+//   - hello_truncated.pcap: 在第二个分段中间被截断
+
+func pcapProfileJA3(t *testing.T, name string) string {
+	t.Helper()
+	sf, _ := Profile(name)
+	fp, err := Compute(sf)
+	if err != nil {
+		t.Fatalf("Compute(%s) error = %v", name, err)
+	}
+	return fp.JA3
+}
+
+func TestFromPcap(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		filter  string
+		profile string
+	}{
+		{"pcap小端", "hello_le.pcap", "", "chrome_124"},
+		{"pcap小端按SNI过滤", "hello_le.pcap", "second.example", "firefox_120"},
+		{"pcap大端IPv6", "hello_be.pcap", "", "chrome_124"},
+		{"pcap大端IPv6按SNI过滤", "hello_be.pcap", "second.example", "firefox_120"},
+		{"pcapng", "hello.pcapng", "", "chrome_124"},
+		{"pcapng按SNI过滤", "hello.pcapng", "second.example", "firefox_120"},
+		{"跨分段", "hello_split.pcap", "first.example", "chrome_124"},
+		{"乱序与重传", "hello_reordered.pcap", "first.example", "chrome_124"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := FromPcap(filepath.Join("testdata", tt.file), tt.filter)
+			if err != nil {
+				t.Fatalf("FromPcap() error = %v", err)
+			}
+			fp, err := Compute(sf)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if want := pcapProfileJA3(t, tt.profile); fp.JA3 != want {
+				t.Errorf("JA3 = %s, want %s (%s)", fp.JA3, want, tt.profile)
+			}
+		})
+	}
+}
+
+func TestFromPcapNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		filter string
+	}{
+		{"SNI不匹配", "hello_le.pcap", "missing.example"},
+		{"截断的抓包", "hello_truncated.pcap", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := FromPcap(filepath.Join("testdata", tt.file), tt.filter)
+			if !errors.Is(err, ErrClientHelloNotFound) {
+				t.Fatalf("FromPcap() error = %v, want ErrClientHelloNotFound", err)
+			}
+			if sf != nil {
+				t.Error("FromPcap() 返回错误时仍返回了 SpecFactory")
+			}
+		})
+	}
+}
+
+// TestFromPcapTruncated 在每个长度截断抓包文件，只允许返回错误，不能 panic
+func TestFromPcapTruncated(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"hello_le.pcap", "hello_be.pcap", "hello.pcapng"} {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 截断到第一个ClientHello结束之前，文件中不可能有完整的ClientHello
+		path := filepath.Join(dir, file)
+		for n := 0; n < len(data)/2; n++ {
+			if err := os.WriteFile(path, data[:n], 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := FromPcap(path, "first.example"); err == nil {
+				t.Fatalf("%s 截断到 %d 字节时 FromPcap() 应返回错误", file, n)
+			}
+		}
+	}
+}
+
+func TestFromPcapInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.pcap")
+	if err := os.WriteFile(path, []byte("not a capture file at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromPcap(path, ""); err == nil || errors.Is(err, ErrClientHelloNotFound) {
+		t.Errorf("FromPcap() error = %v, want 格式错误", err)
+	}
+	if _, err := FromPcap(filepath.Join(t.TempDir(), "missing.pcap"), ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FromPcap() error = %v, want os.ErrNotExist", err)
+	}
+}
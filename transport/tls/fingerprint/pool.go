@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+// ErrEmptyPool 表示指纹池中没有可用的指纹
+var ErrEmptyPool = errors.New("指纹池为空")
+
+// PoolStrategy 指纹池的选择策略
+type PoolStrategy int
+
+const (
+	// PoolWeighted 每次按权重随机选择
+	PoolWeighted PoolStrategy = iota
+	// PoolRoundRobin 按顺序轮流选择，忽略权重
+	PoolRoundRobin
+	// PoolStickyHost 按主机名的哈希值以权重选择，同一主机固定使用同一指纹，不为每个主机保存状态
+	PoolStickyHost
+)
+
+// PoolEntry 指纹池中的一项。Spec 为空时按 Name 查找内置预设；Weight 小于等于0时视为1
+type PoolEntry struct {
+	Name   string
+	Spec   SpecFactory
+	Weight int
+}
+
+// Pool 在多个指纹之间按策略选择，用于在每次连接时轮换ClientHello，可以安全地并发使用
+type Pool struct {
+	strategy PoolStrategy
+	entries  []PoolEntry
+	total    int
+
+	mu   sync.Mutex
+	next int
+	seed maphash.Seed
+}
+
+// NewPool 创建指纹池，如
+//
+//	pool, err := fingerprint.NewPool(fingerprint.PoolWeighted,
+//		fingerprint.PoolEntry{Name: "chrome_124", Weight: 6},
+//		fingerprint.PoolEntry{Name: "firefox_120", Weight: 3},
+//		fingerprint.PoolEntry{Name: "safari_17_0", Weight: 1},
+//	)
+func NewPool(strategy PoolStrategy, entries ...PoolEntry) (*Pool, error) {
+	if len(entries) == 0 {
+		return nil, ErrEmptyPool
+	}
+	switch strategy {
+	case PoolWeighted, PoolRoundRobin, PoolStickyHost:
+	default:
+		return nil, fmt.Errorf("未知的指纹池策略: %d", strategy)
+	}
+
+	p := &Pool{
+		strategy: strategy,
+		entries:  make([]PoolEntry, 0, len(entries)),
+		seed:     maphash.MakeSeed(),
+	}
+	for _, entry := range entries {
+		if entry.Spec == nil {
+			sf, err := Profile(entry.Name)
+			if err != nil {
+				return nil, err
+			}
+			entry.Spec = sf
+		}
+		if entry.Weight <= 0 {
+			entry.Weight = 1
+		}
+		p.entries = append(p.entries, entry)
+		p.total += entry.Weight
+	}
+	return p, nil
+}
+
+// Select 为一次连接选择指纹，host 为目标主机名，仅 PoolStickyHost 策略使用
+func (p *Pool) Select(host string) PoolEntry {
+	switch p.strategy {
+	case PoolRoundRobin:
+		p.mu.Lock()
+		i := p.next
+		p.next = (p.next + 1) % len(p.entries)
+		p.mu.Unlock()
+		return p.entries[i]
+	case PoolStickyHost:
+		p.mu.Lock()
+		seed := p.seed
+		p.mu.Unlock()
+		h := maphash.String(seed, strings.ToLower(host))
+		return p.entries[p.byWeight(int(h%uint64(p.total)))]
+	default:
+		return p.entries[p.byWeight(rand.IntN(p.total))]
+	}
+}
+
+// Reset 清除轮询位置，并重新生成哈希种子使主机与指纹重新对应
+func (p *Pool) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next = 0
+	p.seed = maphash.MakeSeed()
+}
+
+// byWeight 将 [0, total) 中的 n 按权重映射为下标
+func (p *Pool) byWeight(n int) int {
+	for i, entry := range p.entries {
+		if n < entry.Weight {
+			return i
+		}
+		n -= entry.Weight
+	}
+	return len(p.entries) - 1
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPoolStickyHost(t *testing.T) {
+	pool, err := NewPool(PoolStickyHost,
+		PoolEntry{Name: "chrome_124", Weight: 3},
+		PoolEntry{Name: "firefox_120", Weight: 1},
+	)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+
+	first := pool.Select("Example.com").Name
+	for range 100 {
+		if got := pool.Select("example.COM").Name; got != first {
+			t.Fatalf("同一主机选择了不同的指纹: %s, %s", first, got)
+		}
+	}
+
+	// 主机数量很大时按权重分布
+	counts := make(map[string]int)
+	const hosts = 20000
+	for i := range hosts {
+		counts[pool.Select(fmt.Sprintf("host-%d.example.com", i)).Name]++
+	}
+	if ratio := float64(counts["chrome_124"]) / hosts; ratio < 0.70 || ratio > 0.80 {
+		t.Errorf("chrome_124 占比 = %.3f, want ~0.75", ratio)
+	}
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	pool, err := NewPool(PoolRoundRobin, PoolEntry{Name: "chrome_124"}, PoolEntry{Name: "firefox_120"})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	want := []string{"chrome_124", "firefox_120", "chrome_124"}
+	for i, name := range want {
+		if got := pool.Select("").Name; got != name {
+			t.Errorf("第%d次选择 = %s, want %s", i+1, got, name)
+		}
+	}
+	pool.Reset()
+	if got := pool.Select("").Name; got != "chrome_124" {
+		t.Errorf("Reset 后选择 = %s, want chrome_124", got)
+	}
+}
+
+func TestNewPoolErrors(t *testing.T) {
+	if _, err := NewPool(PoolWeighted); err != ErrEmptyPool {
+		t.Errorf("NewPool() error = %v, want ErrEmptyPool", err)
+	}
+	if _, err := NewPool(PoolWeighted, PoolEntry{Name: "no_such_profile"}); err == nil {
+		t.Error("未知的预设名称应返回错误")
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"slices"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// WithPostQuantumKeyShare 包装 SpecFactory，在 supported_groups 和 key_share 的首位 (GREASE之后) 加入
+// X25519MLKEM768 混合后量子密钥交换 (Chrome 131+ 与 Firefox 132+ 的默认行为)。
+// 已携带 X25519MLKEM768 或 X25519Kyber768Draft00 的扩展保持不变，避免同时发送两个后量子密钥共享。
+// 服务器不支持时会忽略该密钥共享，或通过 HelloRetryRequest 回退到经典曲线
+func WithPostQuantumKeyShare(sf SpecFactory) SpecFactory {
+	return func() *utls.ClientHelloSpec {
+		spec := sf()
+		for _, ext := range spec.Extensions {
+			switch e := ext.(type) {
+			case *utls.SupportedCurvesExtension:
+				if !slices.ContainsFunc(e.Curves, isPostQuantumGroup) {
+					i := 0
+					for i < len(e.Curves) && isGREASE(uint16(e.Curves[i])) {
+						i++
+					}
+					e.Curves = slices.Insert(slices.Clone(e.Curves), i, utls.X25519MLKEM768)
+				}
+			case *utls.KeyShareExtension:
+				if !slices.ContainsFunc(e.KeyShares, func(ks utls.KeyShare) bool { return isPostQuantumGroup(ks.Group) }) {
+					i := 0
+					for i < len(e.KeyShares) && isGREASE(uint16(e.KeyShares[i].Group)) {
+						i++
+					}
+					e.KeyShares = slices.Insert(slices.Clone(e.KeyShares), i, utls.KeyShare{Group: utls.X25519MLKEM768})
+				}
+			}
+		}
+		return spec
+	}
+}
+
+// isPostQuantumGroup 判断是否为混合后量子密钥交换组
+func isPostQuantumGroup(group utls.CurveID) bool {
+	return group == utls.X25519MLKEM768 || group == utls.X25519Kyber768Draft00
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// testCertificate 生成 example.com 的自签名证书
+func testCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: computeServerName},
+		DNSNames:     []string{computeServerName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshake 使用指定指纹与只接受 curves 的 crypto/tls 服务端完成握手
+func handshake(t *testing.T, sf SpecFactory, curves []tls.CurveID) error {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+	serverConn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer serverConn.Close()
+
+	server := tls.Server(serverConn, &tls.Config{
+		Certificates:     []tls.Certificate{testCertificate(t)},
+		CurvePreferences: curves,
+		MinVersion:       tls.VersionTLS13,
+	})
+	done := make(chan error, 1)
+	go func() { done <- server.Handshake() }()
+
+	client := utls.UClient(clientConn, &utls.Config{ServerName: computeServerName, InsecureSkipVerify: true}, utls.HelloCustom)
+	if err := client.ApplyPreset(sf()); err != nil {
+		t.Fatalf("ApplyPreset() error = %v", err)
+	}
+	_ = clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := client.Handshake(); err != nil {
+		clientConn.Close()
+		<-done
+		return err
+	}
+	return <-done
+}
+
+func TestWithPostQuantumKeyShare(t *testing.T) {
+	spec := WithPostQuantumKeyShare(GetChrome120ClientHelloSpec)()
+	for _, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedCurvesExtension:
+			if e.Curves[1] != utls.X25519MLKEM768 {
+				t.Errorf("supported_groups = %v, 期望 X25519MLKEM768 紧跟 GREASE", e.Curves)
+			}
+		case *utls.KeyShareExtension:
+			if e.KeyShares[1].Group != utls.X25519MLKEM768 {
+				t.Errorf("key_share[1] = %v, want X25519MLKEM768", e.KeyShares[1].Group)
+			}
+		}
+	}
+
+	// 已携带后量子密钥共享的指纹不重复添加
+	spec = WithPostQuantumKeyShare(GetChrome124ClientHelloSpec)()
+	for _, ext := range spec.Extensions {
+		if e, ok := ext.(*utls.KeyShareExtension); ok && slices.ContainsFunc(e.KeyShares, func(ks utls.KeyShare) bool { return ks.Group == utls.X25519MLKEM768 }) {
+			t.Error("Chrome 124 已携带 X25519Kyber768Draft00，不应再加入 X25519MLKEM768")
+		}
+	}
+}
+
+func TestPostQuantumHandshake(t *testing.T) {
+	factories := map[string]SpecFactory{
+		"chrome_131":  GetChrome131ClientHelloSpec,
+		"firefox_133": GetFirefox133ClientHelloSpec,
+		"chrome_120+": WithPostQuantumKeyShare(GetChrome120ClientHelloSpec),
+	}
+	tests := []struct {
+		name   string
+		curves []tls.CurveID
+	}{
+		{"X25519MLKEM768", []tls.CurveID{tls.X25519MLKEM768}},
+		{"X25519", []tls.CurveID{tls.X25519}},
+		// 客户端未发送 P-384 密钥共享，服务器通过 HelloRetryRequest 要求回退
+		{"HelloRetryRequest", []tls.CurveID{tls.CurveP384}},
+	}
+	for name, sf := range factories {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				if err := handshake(t, sf, tt.curves); err != nil {
+					t.Fatalf("握手失败: %v", err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownProfile 表示请求的指纹预设不存在
+var ErrUnknownProfile = errors.New("未知的指纹预设")
+
+// profiles 内置浏览器指纹预设，名称格式为 <客户端>_<版本>
+var profiles = map[string]SpecFactory{
+	"chrome_120":         GetChrome120ClientHelloSpec,
+	"chrome_124":         GetChrome124ClientHelloSpec,
+	"chrome_131":         GetChrome131ClientHelloSpec,
+	"chrome_android_120": GetChromeAndroid120ClientHelloSpec,
+	"chrome_android_124": GetChromeAndroid124ClientHelloSpec,
+	"chrome_android_131": GetChromeAndroid131ClientHelloSpec,
+	"edge_120":           GetEdge120ClientHelloSpec,
+	"edge_124":           GetEdge124ClientHelloSpec,
+	"edge_131":           GetEdge131ClientHelloSpec,
+	"firefox_120":        GetFirefox120ClientHelloSpec,
+	"firefox_133":        GetFirefox133ClientHelloSpec,
+	"safari_17_0":        GetSafari17ClientHelloSpec,
+	"safari_ios_17_0":    GetSafariIOS17ClientHelloSpec,
+	"okhttp_4":           GetOkHttp4ClientHelloSpec,
+}
+
+// Profile 按名称查找内置指纹预设，名称不区分大小写，如 "chrome_124"
+func Profile(name string) (SpecFactory, error) {
+	sf, ok := profiles[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	return sf, nil
+}
+
+// ProfileNames 返回所有内置指纹预设的名称，按字典序排列
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
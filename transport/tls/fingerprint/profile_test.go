@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"testing"
+)
+
+// goldenFingerprints 内置预设的 JA3 哈希、JA4 与 JA4_r，修改预设时需要同步更新并与真实客户端抓包核对
+var goldenFingerprints = map[string]struct {
+	ja3Hash string
+	ja4     string
+	ja4r    string
+}{
+	"chrome_120": {
+		ja3Hash: "54a1e76e0b5a8108bef9be6e74b32325",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"chrome_124": {
+		ja3Hash: "305646c8f1c5975313f3801b619077db",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"chrome_131": {
+		ja3Hash: "5549240a5133e94d2053bfbc454228ef",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"chrome_android_120": {
+		ja3Hash: "54a1e76e0b5a8108bef9be6e74b32325",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"chrome_android_124": {
+		ja3Hash: "305646c8f1c5975313f3801b619077db",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"chrome_android_131": {
+		ja3Hash: "5549240a5133e94d2053bfbc454228ef",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"edge_120": {
+		ja3Hash: "54a1e76e0b5a8108bef9be6e74b32325",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"edge_124": {
+		ja3Hash: "305646c8f1c5975313f3801b619077db",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"edge_131": {
+		ja3Hash: "5549240a5133e94d2053bfbc454228ef",
+		ja4:     "t13d1516h2_8daaf6152771_02713d6af862",
+		ja4r:    "t13d1516h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,0023,002b,002d,0033,4469,fe0d,ff01_0403,0804,0401,0503,0805,0501,0806,0601",
+	},
+	"firefox_120": {
+		ja3Hash: "b5001237acdf006056b409cc433726b0",
+		ja4:     "t13d1715h2_5b57614c22b0_5c2c66f702b0",
+		ja4r:    "t13d1715h2_002f,0035,009c,009d,1301,1302,1303,c009,c00a,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0017,001c,0022,0023,002b,002d,0033,fe0d,ff01_0403,0503,0603,0804,0805,0806,0401,0501,0601,0203,0201",
+	},
+	"firefox_133": {
+		ja3Hash: "6f7889b9fb1a62a9577e685c1fcfa919",
+		ja4:     "t13d1717h2_5b57614c22b0_3cbfd9057e0d",
+		ja4r:    "t13d1717h2_002f,0035,009c,009d,1301,1302,1303,c009,c00a,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0017,001b,001c,0022,0023,002b,002d,0033,fe0d,ff01_0403,0503,0603,0804,0805,0806,0401,0501,0601,0203,0201",
+	},
+	"okhttp_4": {
+		ja3Hash: "f79b6bad2ad0641e1921aef10262856b",
+		ja4:     "t13d1513h2_8daaf6152771_eca864cca44a",
+		ja4r:    "t13d1513h2_002f,0035,009c,009d,1301,1302,1303,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0015,0017,0023,002b,002d,0033,ff01_0403,0804,0401,0503,0805,0501,0806,0601,0201",
+	},
+	"safari_17_0": {
+		ja3Hash: "773906b0efdefa24a7f2b8eb6985bf37",
+		ja4:     "t13d2014h2_a09f3c656075_14788d8d241b",
+		ja4r:    "t13d2014h2_000a,002f,0035,009c,009d,1301,1302,1303,c008,c009,c00a,c012,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0015,0017,001b,002b,002d,0033,ff01_0403,0804,0401,0503,0203,0805,0805,0501,0806,0601,0201",
+	},
+	"safari_ios_17_0": {
+		ja3Hash: "773906b0efdefa24a7f2b8eb6985bf37",
+		ja4:     "t13d2014h2_a09f3c656075_14788d8d241b",
+		ja4r:    "t13d2014h2_000a,002f,0035,009c,009d,1301,1302,1303,c008,c009,c00a,c012,c013,c014,c02b,c02c,c02f,c030,cca8,cca9_0005,000a,000b,000d,0012,0015,0017,001b,002b,002d,0033,ff01_0403,0804,0401,0503,0203,0805,0805,0501,0806,0601,0201",
+	},
+}
+
+func TestProfileFingerprints(t *testing.T) {
+	for _, name := range ProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			want, ok := goldenFingerprints[name]
+			if !ok {
+				t.Fatalf("预设 %s 缺少指纹基准值", name)
+			}
+			sf, err := Profile(name)
+			if err != nil {
+				t.Fatalf("Profile() error = %v", err)
+			}
+			fp, err := Compute(sf)
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if fp.JA3Hash != want.ja3Hash {
+				t.Errorf("JA3Hash = %s, want %s", fp.JA3Hash, want.ja3Hash)
+			}
+			if fp.JA4 != want.ja4 {
+				t.Errorf("JA4 = %s, want %s", fp.JA4, want.ja4)
+			}
+			if fp.JA4R != want.ja4r {
+				t.Errorf("JA4R = %s, want %s", fp.JA4R, want.ja4r)
+			}
+		})
+	}
+}
+
+func TestProfileUnknown(t *testing.T) {
+	if _, err := Profile("netscape_4"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Profile() error = %v, want ErrUnknownProfile", err)
+	}
+	if _, err := Profile(" Chrome_124 "); err != nil {
+		t.Errorf("Profile() 应忽略大小写与空白, error = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	utls "github.com/refraction-networking/utls"
+)
+
+// GetSafari17ClientHelloSpec 返回 macOS Safari 17.0 的ClientHello规范
+func GetSafari17ClientHelloSpec() *utls.ClientHelloSpec {
+	return appleClientHelloSpec()
+}
+
+// GetSafariIOS17ClientHelloSpec 返回 iOS Safari 17.0 的ClientHello规范
+// iOS 与 macOS 使用同一套系统TLS栈，ClientHello 与 macOS 版一致
+func GetSafariIOS17ClientHelloSpec() *utls.ClientHelloSpec {
+	return appleClientHelloSpec()
+}
+
+// appleClientHelloSpec 构造 Apple 系统TLS栈 (Safari/WebKit) 的ClientHello规范
+func appleClientHelloSpec() *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		TLSVersMin: utls.VersionTLS10,
+		TLSVersMax: utls.VersionTLS13,
+		CipherSuites: []uint16{
+			utls.GREASE_PLACEHOLDER,                         // GREASE前缀
+			utls.TLS_AES_128_GCM_SHA256,                     // 4865
+			utls.TLS_AES_256_GCM_SHA384,                     // 4866
+			utls.TLS_CHACHA20_POLY1305_SHA256,               // 4867
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,    // 49196
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,    // 49195
+			utls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,     // 52393
+			utls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,      // 49200
+			utls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,      // 49199
+			utls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,       // 52392
+			utls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,       // 49162
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,       // 49161
+			utls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,         // 49172
+			utls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,         // 49171
+			utls.TLS_RSA_WITH_AES_256_GCM_SHA384,            // 157
+			utls.TLS_RSA_WITH_AES_128_GCM_SHA256,            // 156
+			utls.TLS_RSA_WITH_AES_256_CBC_SHA,               // 53
+			utls.TLS_RSA_WITH_AES_128_CBC_SHA,               // 47
+			utls.FAKE_TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA, // 49160
+			utls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,        // 49170
+			utls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,              // 10
+		},
+		CompressionMethods: []byte{0}, // 无压缩
+		Extensions: []utls.TLSExtension{
+			&utls.UtlsGREASEExtension{},
+			&utls.SNIExtension{},                  // 0
+			&utls.ExtendedMasterSecretExtension{}, // 23
+			&utls.RenegotiationInfoExtension{ // 65281
+				Renegotiation: utls.RenegotiateOnceAsClient},
+			&utls.SupportedCurvesExtension{ // 10
+				Curves: []utls.CurveID{
+					utls.GREASE_PLACEHOLDER,
+					utls.X25519,    // 29
+					utls.CurveP256, // 23
+					utls.CurveP384, // 24
+					utls.CurveP521, // 25
+				}},
+			&utls.SupportedPointsExtension{ // 11
+				SupportedPoints: []byte{0}},
+			&utls.ALPNExtension{ // 16
+				AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.StatusRequestExtension{}, // 5
+			&utls.SignatureAlgorithmsExtension{ // 13
+				SupportedSignatureAlgorithms: []utls.SignatureScheme{
+					utls.ECDSAWithP256AndSHA256,
+					utls.PSSWithSHA256,
+					utls.PKCS1WithSHA256,
+					utls.ECDSAWithP384AndSHA384,
+					utls.ECDSAWithSHA1,
+					utls.PSSWithSHA384,
+					utls.PSSWithSHA384,
+					utls.PKCS1WithSHA384,
+					utls.PSSWithSHA512,
+					utls.PKCS1WithSHA512,
+					utls.PKCS1WithSHA1,
+				}},
+			&utls.SCTExtension{}, // 18
+			&utls.KeyShareExtension{ // 51
+				KeyShares: []utls.KeyShare{
+					{Group: utls.CurveID(utls.GREASE_PLACEHOLDER), Data: []byte{0}},
+					{Group: utls.X25519},
+				}},
+			&utls.PSKKeyExchangeModesExtension{ // 45
+				Modes: []uint8{utls.PskModeDHE}},
+			&utls.SupportedVersionsExtension{ // 43
+				Versions: []uint16{
+					utls.GREASE_PLACEHOLDER,
+					utls.VersionTLS13,
+					utls.VersionTLS12,
+					utls.VersionTLS11,
+					utls.VersionTLS10}},
+			&utls.UtlsCompressCertExtension{ // 27
+				Algorithms: []utls.CertCompressionAlgo{
+					utls.CertCompressionZlib,
+				}},
+			&utls.UtlsGREASEExtension{},
+			&utls.UtlsPaddingExtension{ // 21
+				GetPaddingLen: utls.BoringPaddingStyle},
+		}}
+}
@@ -0,0 +1,591 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidSpecDocument 表示声明式指纹描述无法转换为 ClientHelloSpec
+var ErrInvalidSpecDocument = errors.New("无效的指纹描述")
+
+// SpecFormat 声明式指纹描述的序列化格式
+type SpecFormat string
+
+const (
+	SpecFormatJSON SpecFormat = "json"
+	SpecFormatYAML SpecFormat = "yaml"
+)
+
+// 扩展类型名称
+const (
+	extTypeServerName           = "server_name"
+	extTypeStatusRequest        = "status_request"
+	extTypeStatusRequestV2      = "status_request_v2"
+	extTypeSupportedGroups      = "supported_groups"
+	extTypeECPointFormats       = "ec_point_formats"
+	extTypeSignatureAlgorithms  = "signature_algorithms"
+	extTypeSignatureAlgsCert    = "signature_algorithms_cert"
+	extTypeALPN                 = "alpn"
+	extTypeApplicationSettings  = "application_settings"
+	extTypeApplicationSettingsN = "application_settings_new"
+	extTypeSCT                  = "signed_certificate_timestamp"
+	extTypePadding              = "padding"
+	extTypeExtendedMasterSecret = "extended_master_secret"
+	extTypeCompressCertificate  = "compress_certificate"
+	extTypeRecordSizeLimit      = "record_size_limit"
+	extTypeDelegatedCredentials = "delegated_credentials"
+	extTypeSessionTicket        = "session_ticket"
+	extTypePreSharedKey         = "pre_shared_key"
+	extTypeSupportedVersions    = "supported_versions"
+	extTypePSKKeyExchangeModes  = "psk_key_exchange_modes"
+	extTypeKeyShare             = "key_share"
+	extTypeNextProtoNeg         = "next_protocol_negotiation"
+	extTypeChannelID            = "channel_id"
+	extTypeEncryptedClientHello = "encrypted_client_hello"
+	extTypeRenegotiationInfo    = "renegotiation_info"
+	extTypeGREASE               = "grease"
+	extTypeGeneric              = "generic"
+)
+
+var renegotiationNames = map[utls.RenegotiationSupport]string{
+	utls.RenegotiateNever:          "never",
+	utls.RenegotiateOnceAsClient:   "once",
+	utls.RenegotiateFreelyAsClient: "freely",
+}
+
+// SpecDocument 声明式的ClientHello描述，可以与 JSON/YAML 互相转换。
+// 密码套件、曲线、签名算法等取值使用IANA名称，也可以写成数值；"GREASE" 表示GREASE占位符。
+type SpecDocument struct {
+	Name               string              `json:"name,omitempty" yaml:"name,omitempty"`
+	TLSVersionMin      string              `json:"tls_version_min,omitempty" yaml:"tls_version_min,omitempty"`
+	TLSVersionMax      string              `json:"tls_version_max,omitempty" yaml:"tls_version_max,omitempty"`
+	CipherSuites       []string            `json:"cipher_suites" yaml:"cipher_suites"`
+	CompressionMethods []int               `json:"compression_methods,omitempty" yaml:"compression_methods,omitempty"`
+	Extensions         []ExtensionDocument `json:"extensions" yaml:"extensions"`
+}
+
+// ExtensionDocument 单个扩展的描述，按 Type 使用对应的参数字段，扩展顺序即数组顺序
+type ExtensionDocument struct {
+	Type string `json:"type" yaml:"type"`
+
+	// generic: 扩展编号和十六进制扩展体；grease: 十六进制扩展体
+	ID   uint16 `json:"id,omitempty" yaml:"id,omitempty"`
+	Data string `json:"data,omitempty" yaml:"data,omitempty"`
+
+	// supported_groups 的曲线列表
+	Groups []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// key_share 中携带公钥的曲线列表
+	KeyShares []string `json:"key_shares,omitempty" yaml:"key_shares,omitempty"`
+	// ec_point_formats 的点格式
+	PointFormats []int `json:"point_formats,omitempty" yaml:"point_formats,omitempty"`
+	// signature_algorithms / signature_algorithms_cert / delegated_credentials 的签名算法
+	SignatureAlgorithms []string `json:"signature_algorithms,omitempty" yaml:"signature_algorithms,omitempty"`
+	// alpn / application_settings / application_settings_new 的协议列表
+	Protocols []string `json:"protocols,omitempty" yaml:"protocols,omitempty"`
+	// supported_versions 的版本列表，如 "1.3"
+	Versions []string `json:"versions,omitempty" yaml:"versions,omitempty"`
+	// psk_key_exchange_modes 的模式
+	Modes []int `json:"modes,omitempty" yaml:"modes,omitempty"`
+	// compress_certificate 的压缩算法，如 "brotli"
+	Algorithms []string `json:"algorithms,omitempty" yaml:"algorithms,omitempty"`
+	// renegotiation_info 的重协商策略: never/once/freely
+	Renegotiation string `json:"renegotiation,omitempty" yaml:"renegotiation,omitempty"`
+	// record_size_limit 的记录长度上限；padding 的固定填充长度，为0时使用 BoringSSL 填充策略
+	Limit  int `json:"limit,omitempty" yaml:"limit,omitempty"`
+	Length int `json:"length,omitempty" yaml:"length,omitempty"`
+	// pre_shared_key 的伪造身份和绑定值长度
+	Identities    []PSKIdentityDocument `json:"identities,omitempty" yaml:"identities,omitempty"`
+	BinderLengths []int                 `json:"binder_lengths,omitempty" yaml:"binder_lengths,omitempty"`
+	// encrypted_client_hello (GREASE ECH) 的候选HPKE套件和载荷长度
+	ECHCipherSuites   []ECHCipherSuiteDocument `json:"ech_cipher_suites,omitempty" yaml:"ech_cipher_suites,omitempty"`
+	ECHPayloadLengths []int                    `json:"ech_payload_lengths,omitempty" yaml:"ech_payload_lengths,omitempty"`
+	// channel_id 是否使用旧扩展编号 30031
+	OldExtensionID bool `json:"old_extension_id,omitempty" yaml:"old_extension_id,omitempty"`
+}
+
+// PSKIdentityDocument pre_shared_key 扩展中的身份，Identity 为十六进制
+type PSKIdentityDocument struct {
+	Identity            string `json:"identity" yaml:"identity"`
+	ObfuscatedTicketAge uint32 `json:"obfuscated_ticket_age" yaml:"obfuscated_ticket_age"`
+}
+
+// ECHCipherSuiteDocument HPKE对称密码套件
+type ECHCipherSuiteDocument struct {
+	KDF  uint16 `json:"kdf" yaml:"kdf"`
+	AEAD uint16 `json:"aead" yaml:"aead"`
+}
+
+// LoadSpecFile 从 JSON/YAML 文件加载指纹描述，格式由文件扩展名 (.json/.yaml/.yml) 决定
+func LoadSpecFile(path string) (SpecFactory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取指纹描述文件失败: %w", err)
+	}
+
+	var format SpecFormat
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = SpecFormatJSON
+	case ".yaml", ".yml":
+		format = SpecFormatYAML
+	default:
+		return nil, fmt.Errorf("%w: 无法识别的文件格式 %s", ErrInvalidSpecDocument, path)
+	}
+	return ParseSpec(data, format)
+}
+
+// ParseSpec 解析 JSON/YAML 格式的指纹描述并转换为 SpecFactory
+func ParseSpec(data []byte, format SpecFormat) (SpecFactory, error) {
+	doc := &SpecDocument{}
+	var err error
+	switch format {
+	case SpecFormatJSON:
+		err = json.Unmarshal(data, doc)
+	case SpecFormatYAML:
+		err = yaml.Unmarshal(data, doc)
+	default:
+		return nil, fmt.Errorf("%w: 不支持的格式 %s", ErrInvalidSpecDocument, format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpecDocument, err)
+	}
+	return doc.Factory()
+}
+
+// MarshalSpec 将 SpecFactory 导出为 JSON/YAML 格式的指纹描述
+func MarshalSpec(sf SpecFactory, format SpecFormat) ([]byte, error) {
+	doc, err := ExportSpec(sf)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case SpecFormatJSON:
+		return json.MarshalIndent(doc, "", "  ")
+	case SpecFormatYAML:
+		return yaml.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("%w: 不支持的格式 %s", ErrInvalidSpecDocument, format)
+	}
+}
+
+// ExportSpec 将 SpecFactory 转换为声明式描述，如 ExportSpec(GetDefaultClientHelloSpec)
+func ExportSpec(sf SpecFactory) (*SpecDocument, error) {
+	spec := sf()
+	doc := &SpecDocument{
+		CipherSuites: formatValues(spec.CipherSuites, cipherSuiteNames),
+	}
+	if spec.TLSVersMin != 0 {
+		doc.TLSVersionMin = formatValue(spec.TLSVersMin, versionNames)
+	}
+	if spec.TLSVersMax != 0 {
+		doc.TLSVersionMax = formatValue(spec.TLSVersMax, versionNames)
+	}
+	for _, m := range spec.CompressionMethods {
+		doc.CompressionMethods = append(doc.CompressionMethods, int(m))
+	}
+	for _, ext := range spec.Extensions {
+		extDoc, err := exportExtension(ext)
+		if err != nil {
+			return nil, err
+		}
+		doc.Extensions = append(doc.Extensions, extDoc)
+	}
+	return doc, nil
+}
+
+// Factory 校验描述并返回 SpecFactory，每次调用 SpecFactory 都会生成独立的 ClientHelloSpec
+func (d *SpecDocument) Factory() (SpecFactory, error) {
+	if _, err := d.build(); err != nil {
+		return nil, err
+	}
+	sf := func() *utls.ClientHelloSpec {
+		spec, _ := d.build()
+		return spec
+	}
+	// 预先构造一次ClientHello，pre_shared_key 不在末尾等 utls 无法生成的描述在加载时报错，而不是在握手时 panic
+	if _, err := marshalClientHello(sf, computeServerName); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSpecDocument, err)
+	}
+	return sf, nil
+}
+
+func (d *SpecDocument) build() (*utls.ClientHelloSpec, error) {
+	spec := &utls.ClientHelloSpec{}
+	var err error
+
+	if d.TLSVersionMin != "" {
+		if spec.TLSVersMin, err = parseValue(d.TLSVersionMin, versionNames); err != nil {
+			return nil, fmt.Errorf("%w: tls_version_min %v", ErrInvalidSpecDocument, err)
+		}
+	}
+	if d.TLSVersionMax != "" {
+		if spec.TLSVersMax, err = parseValue(d.TLSVersionMax, versionNames); err != nil {
+			return nil, fmt.Errorf("%w: tls_version_max %v", ErrInvalidSpecDocument, err)
+		}
+	}
+	if len(d.CipherSuites) == 0 {
+		return nil, fmt.Errorf("%w: cipher_suites 不能为空", ErrInvalidSpecDocument)
+	}
+	if spec.CipherSuites, err = parseValues(d.CipherSuites, cipherSuiteNames); err != nil {
+		return nil, fmt.Errorf("%w: cipher_suites %v", ErrInvalidSpecDocument, err)
+	}
+	if spec.CompressionMethods, err = toUint8s(d.CompressionMethods); err != nil {
+		return nil, fmt.Errorf("%w: compression_methods %v", ErrInvalidSpecDocument, err)
+	}
+	if len(spec.CompressionMethods) == 0 {
+		spec.CompressionMethods = []byte{0} // 无压缩
+	}
+
+	for i, extDoc := range d.Extensions {
+		ext, err := extDoc.build()
+		if err != nil {
+			return nil, fmt.Errorf("%w: 第%d个扩展 (%s) %v", ErrInvalidSpecDocument, i+1, extDoc.Type, err)
+		}
+		spec.Extensions = append(spec.Extensions, ext)
+	}
+	return spec, nil
+}
+
+func (e *ExtensionDocument) build() (utls.TLSExtension, error) {
+	switch e.Type {
+	case extTypeServerName:
+		return &utls.SNIExtension{}, nil
+	case extTypeStatusRequest:
+		return &utls.StatusRequestExtension{}, nil
+	case extTypeStatusRequestV2:
+		return &utls.StatusRequestV2Extension{}, nil
+	case extTypeSupportedGroups:
+		groups, err := parseValues(e.Groups, groupNames)
+		if err != nil {
+			return nil, err
+		}
+		return &utls.SupportedCurvesExtension{Curves: toCurveIDs(groups)}, nil
+	case extTypeECPointFormats:
+		points, err := toUint8s(e.PointFormats)
+		if err != nil {
+			return nil, err
+		}
+		return &utls.SupportedPointsExtension{SupportedPoints: points}, nil
+	case extTypeSignatureAlgorithms, extTypeSignatureAlgsCert, extTypeDelegatedCredentials:
+		values, err := parseValues(e.SignatureAlgorithms, signatureSchemeNames)
+		if err != nil {
+			return nil, err
+		}
+		schemes := make([]utls.SignatureScheme, 0, len(values))
+		for _, v := range values {
+			schemes = append(schemes, utls.SignatureScheme(v))
+		}
+		switch e.Type {
+		case extTypeSignatureAlgsCert:
+			return &utls.SignatureAlgorithmsCertExtension{SupportedSignatureAlgorithms: schemes}, nil
+		case extTypeDelegatedCredentials:
+			return &utls.FakeDelegatedCredentialsExtension{SupportedSignatureAlgorithms: schemes}, nil
+		default:
+			return &utls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: schemes}, nil
+		}
+	case extTypeALPN:
+		return &utls.ALPNExtension{AlpnProtocols: cloneStrings(e.Protocols)}, nil
+	case extTypeApplicationSettings:
+		return &utls.ApplicationSettingsExtension{SupportedProtocols: cloneStrings(e.Protocols)}, nil
+	case extTypeApplicationSettingsN:
+		return &utls.ApplicationSettingsExtensionNew{SupportedProtocols: cloneStrings(e.Protocols)}, nil
+	case extTypeSCT:
+		return &utls.SCTExtension{}, nil
+	case extTypePadding:
+		if e.Length < 0 || e.Length > 0xffff {
+			return nil, fmt.Errorf("无效的填充长度 %d", e.Length)
+		}
+		if e.Length > 0 {
+			return &utls.UtlsPaddingExtension{PaddingLen: e.Length, WillPad: true}, nil
+		}
+		return &utls.UtlsPaddingExtension{GetPaddingLen: utls.BoringPaddingStyle}, nil
+	case extTypeExtendedMasterSecret:
+		return &utls.ExtendedMasterSecretExtension{}, nil
+	case extTypeCompressCertificate:
+		values, err := parseValues(e.Algorithms, certCompressionNames)
+		if err != nil {
+			return nil, err
+		}
+		algos := make([]utls.CertCompressionAlgo, 0, len(values))
+		for _, v := range values {
+			algos = append(algos, utls.CertCompressionAlgo(v))
+		}
+		return &utls.UtlsCompressCertExtension{Algorithms: algos}, nil
+	case extTypeRecordSizeLimit:
+		if e.Limit <= 0 || e.Limit > 0xffff {
+			return nil, fmt.Errorf("无效的记录长度上限 %d", e.Limit)
+		}
+		return &utls.FakeRecordSizeLimitExtension{Limit: uint16(e.Limit)}, nil
+	case extTypeSessionTicket:
+		return &utls.SessionTicketExtension{}, nil
+	case extTypePreSharedKey:
+		if len(e.Identities) == 0 {
+			return defaultFakePSK(), nil
+		}
+		psk := &utls.FakePreSharedKeyExtension{}
+		for _, id := range e.Identities {
+			label, err := hex.DecodeString(id.Identity)
+			if err != nil {
+				return nil, fmt.Errorf("无效的PSK身份 %q", id.Identity)
+			}
+			psk.Identities = append(psk.Identities, utls.PskIdentity{Label: label, ObfuscatedTicketAge: id.ObfuscatedTicketAge})
+		}
+		for _, n := range e.BinderLengths {
+			// RFC 8446 4.2.11: PskBinderEntry<32..255>
+			if n < 32 || n > 255 {
+				return nil, fmt.Errorf("无效的PSK绑定值长度 %d，应在 32-255 之间", n)
+			}
+			psk.Binders = append(psk.Binders, make([]byte, n))
+		}
+		if len(psk.Binders) != len(psk.Identities) {
+			return nil, fmt.Errorf("PSK身份数量 (%d) 与绑定值数量 (%d) 不一致", len(psk.Identities), len(psk.Binders))
+		}
+		return (utls.PreSharedKeyExtension)(psk), nil
+	case extTypeSupportedVersions:
+		versions, err := parseValues(e.Versions, versionNames)
+		if err != nil {
+			return nil, err
+		}
+		return &utls.SupportedVersionsExtension{Versions: versions}, nil
+	case extTypePSKKeyExchangeModes:
+		modes, err := toUint8s(e.Modes)
+		if err != nil {
+			return nil, err
+		}
+		return &utls.PSKKeyExchangeModesExtension{Modes: modes}, nil
+	case extTypeKeyShare:
+		groups, err := parseValues(e.KeyShares, groupNames)
+		if err != nil {
+			return nil, err
+		}
+		shares := make([]utls.KeyShare, 0, len(groups))
+		for _, g := range toCurveIDs(groups) {
+			if g == utls.GREASE_PLACEHOLDER {
+				shares = append(shares, utls.KeyShare{Group: g, Data: []byte{0}})
+			} else {
+				shares = append(shares, utls.KeyShare{Group: g})
+			}
+		}
+		return &utls.KeyShareExtension{KeyShares: shares}, nil
+	case extTypeNextProtoNeg:
+		return &utls.NPNExtension{}, nil
+	case extTypeChannelID:
+		return &utls.FakeChannelIDExtension{OldExtensionID: e.OldExtensionID}, nil
+	case extTypeEncryptedClientHello:
+		if len(e.ECHCipherSuites) == 0 && len(e.ECHPayloadLengths) == 0 {
+			return utls.BoringGREASEECH(), nil
+		}
+		ech := &utls.GREASEEncryptedClientHelloExtension{}
+		for _, cs := range e.ECHCipherSuites {
+			ech.CandidateCipherSuites = append(ech.CandidateCipherSuites, utls.HPKESymmetricCipherSuite{KdfId: cs.KDF, AeadId: cs.AEAD})
+		}
+		for _, n := range e.ECHPayloadLengths {
+			if n < 0 || n > 0xffff {
+				return nil, fmt.Errorf("无效的ECH载荷长度 %d", n)
+			}
+			ech.CandidatePayloadLens = append(ech.CandidatePayloadLens, uint16(n))
+		}
+		return ech, nil
+	case extTypeRenegotiationInfo:
+		if e.Renegotiation == "" {
+			return &utls.RenegotiationInfoExtension{Renegotiation: utls.RenegotiateOnceAsClient}, nil
+		}
+		for mode, name := range renegotiationNames {
+			if name == e.Renegotiation {
+				return &utls.RenegotiationInfoExtension{Renegotiation: mode}, nil
+			}
+		}
+		return nil, fmt.Errorf("未知的重协商策略 %q", e.Renegotiation)
+	case extTypeGREASE:
+		body, err := hex.DecodeString(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("无效的扩展体 %q", e.Data)
+		}
+		return &utls.UtlsGREASEExtension{Body: body}, nil
+	case extTypeGeneric:
+		data, err := hex.DecodeString(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("无效的扩展体 %q", e.Data)
+		}
+		return &utls.GenericExtension{Id: e.ID, Data: data}, nil
+	default:
+		return nil, fmt.Errorf("未知的扩展类型")
+	}
+}
+
+// exportExtension 将 utls 扩展转换为声明式描述，无法识别的扩展按原始字节导出为 generic
+func exportExtension(ext utls.TLSExtension) (ExtensionDocument, error) {
+	switch e := ext.(type) {
+	case *utls.SNIExtension:
+		return ExtensionDocument{Type: extTypeServerName}, nil
+	case *utls.StatusRequestExtension:
+		return ExtensionDocument{Type: extTypeStatusRequest}, nil
+	case *utls.StatusRequestV2Extension:
+		return ExtensionDocument{Type: extTypeStatusRequestV2}, nil
+	case *utls.SupportedCurvesExtension:
+		return ExtensionDocument{Type: extTypeSupportedGroups, Groups: formatValues(fromCurveIDs(e.Curves), groupNames)}, nil
+	case *utls.SupportedPointsExtension:
+		doc := ExtensionDocument{Type: extTypeECPointFormats}
+		for _, p := range e.SupportedPoints {
+			doc.PointFormats = append(doc.PointFormats, int(p))
+		}
+		return doc, nil
+	case *utls.SignatureAlgorithmsExtension:
+		return ExtensionDocument{Type: extTypeSignatureAlgorithms, SignatureAlgorithms: formatSchemes(e.SupportedSignatureAlgorithms)}, nil
+	case *utls.SignatureAlgorithmsCertExtension:
+		return ExtensionDocument{Type: extTypeSignatureAlgsCert, SignatureAlgorithms: formatSchemes(e.SupportedSignatureAlgorithms)}, nil
+	case *utls.FakeDelegatedCredentialsExtension:
+		return ExtensionDocument{Type: extTypeDelegatedCredentials, SignatureAlgorithms: formatSchemes(e.SupportedSignatureAlgorithms)}, nil
+	case *utls.ALPNExtension:
+		return ExtensionDocument{Type: extTypeALPN, Protocols: cloneStrings(e.AlpnProtocols)}, nil
+	case *utls.ApplicationSettingsExtension:
+		return ExtensionDocument{Type: extTypeApplicationSettings, Protocols: cloneStrings(e.SupportedProtocols)}, nil
+	case *utls.ApplicationSettingsExtensionNew:
+		return ExtensionDocument{Type: extTypeApplicationSettingsN, Protocols: cloneStrings(e.SupportedProtocols)}, nil
+	case *utls.SCTExtension:
+		return ExtensionDocument{Type: extTypeSCT}, nil
+	case *utls.UtlsPaddingExtension:
+		if e.GetPaddingLen == nil && e.WillPad {
+			return ExtensionDocument{Type: extTypePadding, Length: e.PaddingLen}, nil
+		}
+		return ExtensionDocument{Type: extTypePadding}, nil
+	case *utls.ExtendedMasterSecretExtension:
+		return ExtensionDocument{Type: extTypeExtendedMasterSecret}, nil
+	case *utls.UtlsCompressCertExtension:
+		values := make([]uint16, 0, len(e.Algorithms))
+		for _, a := range e.Algorithms {
+			values = append(values, uint16(a))
+		}
+		return ExtensionDocument{Type: extTypeCompressCertificate, Algorithms: formatValues(values, certCompressionNames)}, nil
+	case *utls.FakeRecordSizeLimitExtension:
+		return ExtensionDocument{Type: extTypeRecordSizeLimit, Limit: int(e.Limit)}, nil
+	case *utls.SessionTicketExtension:
+		return ExtensionDocument{Type: extTypeSessionTicket}, nil
+	case *utls.FakePreSharedKeyExtension:
+		doc := ExtensionDocument{Type: extTypePreSharedKey}
+		for _, id := range e.Identities {
+			doc.Identities = append(doc.Identities, PSKIdentityDocument{Identity: hex.EncodeToString(id.Label), ObfuscatedTicketAge: id.ObfuscatedTicketAge})
+		}
+		for _, b := range e.Binders {
+			doc.BinderLengths = append(doc.BinderLengths, len(b))
+		}
+		return doc, nil
+	case utls.PreSharedKeyExtension:
+		// 真实PSK依赖会话状态，只导出扩展位置
+		return ExtensionDocument{Type: extTypePreSharedKey}, nil
+	case *utls.SupportedVersionsExtension:
+		return ExtensionDocument{Type: extTypeSupportedVersions, Versions: formatValues(e.Versions, versionNames)}, nil
+	case *utls.PSKKeyExchangeModesExtension:
+		doc := ExtensionDocument{Type: extTypePSKKeyExchangeModes}
+		for _, m := range e.Modes {
+			doc.Modes = append(doc.Modes, int(m))
+		}
+		return doc, nil
+	case *utls.KeyShareExtension:
+		groups := make([]utls.CurveID, 0, len(e.KeyShares))
+		for _, ks := range e.KeyShares {
+			groups = append(groups, ks.Group)
+		}
+		return ExtensionDocument{Type: extTypeKeyShare, KeyShares: formatValues(fromCurveIDs(groups), groupNames)}, nil
+	case *utls.NPNExtension:
+		return ExtensionDocument{Type: extTypeNextProtoNeg}, nil
+	case *utls.FakeChannelIDExtension:
+		return ExtensionDocument{Type: extTypeChannelID, OldExtensionID: e.OldExtensionID}, nil
+	case *utls.GREASEEncryptedClientHelloExtension:
+		doc := ExtensionDocument{Type: extTypeEncryptedClientHello}
+		for _, cs := range e.CandidateCipherSuites {
+			doc.ECHCipherSuites = append(doc.ECHCipherSuites, ECHCipherSuiteDocument{KDF: cs.KdfId, AEAD: cs.AeadId})
+		}
+		for _, n := range e.CandidatePayloadLens {
+			doc.ECHPayloadLengths = append(doc.ECHPayloadLengths, int(n))
+		}
+		return doc, nil
+	case *utls.RenegotiationInfoExtension:
+		return ExtensionDocument{Type: extTypeRenegotiationInfo, Renegotiation: renegotiationNames[e.Renegotiation]}, nil
+	case *utls.UtlsGREASEExtension:
+		return ExtensionDocument{Type: extTypeGREASE, Data: hex.EncodeToString(e.Body)}, nil
+	case *utls.GenericExtension:
+		return ExtensionDocument{Type: extTypeGeneric, ID: e.Id, Data: hex.EncodeToString(e.Data)}, nil
+	default:
+		raw := make([]byte, ext.Len())
+		if _, err := ext.Read(raw); err != nil && !errors.Is(err, io.EOF) || len(raw) < 4 {
+			return ExtensionDocument{}, fmt.Errorf("%w: 无法导出扩展 %T", ErrInvalidSpecDocument, ext)
+		}
+		return ExtensionDocument{Type: extTypeGeneric, ID: uint16(raw[0])<<8 | uint16(raw[1]), Data: hex.EncodeToString(raw[4:])}, nil
+	}
+}
+
+// defaultFakePSK 返回与 GetDefaultClientHelloSpec 相同的伪造PSK扩展
+func defaultFakePSK() utls.PreSharedKeyExtension {
+	return &utls.FakePreSharedKeyExtension{
+		Identities: []utls.PskIdentity{
+			{
+				Label:               []byte("identity"),
+				ObfuscatedTicketAge: 0,
+			},
+		},
+		Binders: [][]byte{make([]byte, 32)},
+	}
+}
+
+// toUint8s 将整数列表转换为 uint8，超出 0-255 时返回错误而不是截断
+func toUint8s(values []int) ([]uint8, error) {
+	out := make([]uint8, 0, len(values))
+	for _, v := range values {
+		if v < 0 || v > 0xff {
+			return nil, fmt.Errorf("取值 %d 超出范围 0-255", v)
+		}
+		out = append(out, uint8(v))
+	}
+	return out, nil
+}
+
+func toCurveIDs(values []uint16) []utls.CurveID {
+	curves := make([]utls.CurveID, 0, len(values))
+	for _, v := range values {
+		curves = append(curves, utls.CurveID(v))
+	}
+	return curves
+}
+
+func fromCurveIDs(curves []utls.CurveID) []uint16 {
+	values := make([]uint16, 0, len(curves))
+	for _, c := range curves {
+		values = append(values, uint16(c))
+	}
+	return values
+}
+
+func formatSchemes(schemes []utls.SignatureScheme) []string {
+	values := make([]uint16, 0, len(schemes))
+	for _, s := range schemes {
+		values = append(values, uint16(s))
+	}
+	return formatValues(values, signatureSchemeNames)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSpecRejectsOutOfRangeValues(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"负数绑定值长度", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"pre_shared_key","identities":[{"identity":"00"}],"binder_lengths":[-1]}]}`},
+		{"过长绑定值长度", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"pre_shared_key","identities":[{"identity":"00"}],"binder_lengths":[256]}]}`},
+		{"压缩方法超出范围", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"compression_methods":[256],"extensions":[]}`},
+		{"点格式超出范围", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"ec_point_formats","point_formats":[256]}]}`},
+		{"负数点格式", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"ec_point_formats","point_formats":[-1]}]}`},
+		{"PSK模式超出范围", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"psk_key_exchange_modes","modes":[300]}]}`},
+		{"ECH载荷长度超出范围", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"encrypted_client_hello","ech_payload_lengths":[65536]}]}`},
+		{"负数填充长度", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"padding","length":-5}]}`},
+		{"密码套件超出范围", `{"cipher_suites":["70000"],"extensions":[]}`},
+		{"pre_shared_key不在末尾", `{"cipher_suites":["TLS_AES_128_GCM_SHA256"],"extensions":[{"type":"supported_versions","versions":["1.3"]},{"type":"psk_key_exchange_modes","modes":[1]},{"type":"pre_shared_key"},{"type":"extended_master_secret"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf, err := ParseSpec([]byte(tt.doc), SpecFormatJSON)
+			if !errors.Is(err, ErrInvalidSpecDocument) {
+				t.Fatalf("ParseSpec() error = %v, want ErrInvalidSpecDocument", err)
+			}
+			if sf != nil {
+				t.Fatal("ParseSpec() 返回了非空的 SpecFactory")
+			}
+		})
+	}
+}
+
+func TestParseSpecRoundTrip(t *testing.T) {
+	for _, name := range ProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			sf, _ := Profile(name)
+			data, err := MarshalSpec(sf, SpecFormatYAML)
+			if err != nil {
+				t.Fatalf("MarshalSpec() error = %v", err)
+			}
+			parsed, err := ParseSpec(data, SpecFormatYAML)
+			if err != nil {
+				t.Fatalf("ParseSpec() error = %v", err)
+			}
+			diffs, err := Diff(sf, parsed)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if len(diffs) > 0 {
+				t.Errorf("导出再导入后指纹不一致: %v", diffs)
+			}
+		})
+	}
+}
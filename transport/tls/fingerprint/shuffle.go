@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"math/rand/v2"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// ShuffleExtensions 包装 SpecFactory，每次生成ClientHello时按 Chrome 110+ 的方式随机排列扩展顺序:
+// 首尾的GREASE扩展保持原位，padding 与 pre_shared_key 固定在末尾且 pre_shared_key 为最后一个扩展。
+// 扩展集合不变，因此 JA4 保持稳定而 JA3 会随每次连接变化
+func ShuffleExtensions(sf SpecFactory) SpecFactory {
+	return func() *utls.ClientHelloSpec {
+		spec := sf()
+		spec.Extensions = shuffleExtensions(spec.Extensions)
+		return spec
+	}
+}
+
+func shuffleExtensions(exts []utls.TLSExtension) []utls.TLSExtension {
+	var (
+		middle  = make([]utls.TLSExtension, 0, len(exts))
+		padding []utls.TLSExtension
+		psk     []utls.TLSExtension
+	)
+	for _, ext := range exts {
+		switch ext.(type) {
+		case *utls.UtlsPaddingExtension:
+			padding = append(padding, ext)
+		case utls.PreSharedKeyExtension:
+			psk = append(psk, ext)
+		default:
+			middle = append(middle, ext)
+		}
+	}
+
+	var head, tail []utls.TLSExtension
+	if len(middle) > 0 {
+		if _, ok := middle[0].(*utls.UtlsGREASEExtension); ok {
+			head, middle = middle[:1], middle[1:]
+		}
+	}
+	if len(middle) > 0 {
+		if _, ok := middle[len(middle)-1].(*utls.UtlsGREASEExtension); ok {
+			middle, tail = middle[:len(middle)-1], middle[len(middle)-1:]
+		}
+	}
+
+	rand.Shuffle(len(middle), func(i, j int) {
+		middle[i], middle[j] = middle[j], middle[i]
+	})
+
+	shuffled := make([]utls.TLSExtension, 0, len(exts))
+	shuffled = append(shuffled, head...)
+	shuffled = append(shuffled, middle...)
+	shuffled = append(shuffled, tail...)
+	shuffled = append(shuffled, padding...)
+	return append(shuffled, psk...)
+}
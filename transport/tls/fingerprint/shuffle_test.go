@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// chromeWithPSK Chrome 124 加上 padding 与 pre_shared_key，覆盖所有需要固定位置的扩展
+func chromeWithPSK() *utls.ClientHelloSpec {
+	spec := GetChrome124ClientHelloSpec()
+	spec.Extensions = append(spec.Extensions,
+		&utls.UtlsPaddingExtension{GetPaddingLen: utls.BoringPaddingStyle},
+		defaultFakePSK(),
+	)
+	return spec
+}
+
+func TestShuffleExtensionsJA4Stable(t *testing.T) {
+	sf := ShuffleExtensions(chromeWithPSK)
+	want, err := Compute(chromeWithPSK)
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+
+	ja3s := make(map[string]bool)
+	for i := range 50 {
+		fp, err := Compute(sf)
+		if err != nil {
+			t.Fatalf("Compute() error = %v", err)
+		}
+		if fp.JA4 != want.JA4 || fp.JA4R != want.JA4R {
+			t.Fatalf("第%d次生成的JA4指纹发生变化: %s, want %s", i+1, fp.JA4, want.JA4)
+		}
+		ja3s[fp.JA3] = true
+	}
+	if len(ja3s) < 2 {
+		t.Error("扩展顺序没有被打乱，JA3 在50次生成中保持不变")
+	}
+}
+
+func TestShuffleExtensionsPlacement(t *testing.T) {
+	sf := ShuffleExtensions(chromeWithPSK)
+	for range 50 {
+		exts := sf().Extensions
+		n := len(exts)
+		if n != len(chromeWithPSK().Extensions) {
+			t.Fatalf("扩展数量 = %d, want %d", n, len(chromeWithPSK().Extensions))
+		}
+		if _, ok := exts[0].(*utls.UtlsGREASEExtension); !ok {
+			t.Fatalf("第一个扩展 = %T, want GREASE", exts[0])
+		}
+		if _, ok := exts[n-3].(*utls.UtlsGREASEExtension); !ok {
+			t.Fatalf("padding 之前的扩展 = %T, want GREASE", exts[n-3])
+		}
+		if _, ok := exts[n-2].(*utls.UtlsPaddingExtension); !ok {
+			t.Fatalf("倒数第二个扩展 = %T, want padding", exts[n-2])
+		}
+		if _, ok := exts[n-1].(utls.PreSharedKeyExtension); !ok {
+			t.Fatalf("最后一个扩展 = %T, want pre_shared_key", exts[n-1])
+		}
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// WarningCode 指纹校验问题的类别
+type WarningCode string
+
+const (
+	WarnEmptyCipherSuites           WarningCode = "empty_cipher_suites"
+	WarnDuplicateExtension          WarningCode = "duplicate_extension"
+	WarnPSKNotLast                  WarningCode = "psk_not_last"
+	WarnPSKWithoutModes             WarningCode = "psk_without_key_exchange_modes"
+	WarnTLS13WithoutSupportedVers   WarningCode = "tls13_suites_without_supported_versions"
+	WarnTLS13WithoutSuites          WarningCode = "tls13_without_cipher_suites"
+	WarnTLS12WithoutSuites          WarningCode = "tls12_without_cipher_suites"
+	WarnVersionRangeMismatch        WarningCode = "version_range_mismatch"
+	WarnTLS13WithoutKeyShare        WarningCode = "tls13_without_key_share"
+	WarnTLS13WithoutSignatureAlgs   WarningCode = "tls13_without_signature_algorithms"
+	WarnKeyShareNotInGroups         WarningCode = "key_share_not_in_supported_groups"
+	WarnECDHEWithoutSupportedGroups WarningCode = "ecdhe_without_supported_groups"
+	WarnALPSWithoutALPN             WarningCode = "alps_without_alpn"
+	WarnALPSProtocolNotInALPN       WarningCode = "alps_protocol_not_in_alpn"
+)
+
+// Warning 指纹校验发现的一处问题，Extension 为相关扩展的编号 (无关时为0)
+type Warning struct {
+	Code      WarningCode
+	Extension uint16
+	Message   string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("[%s] %s", w.Code, w.Message)
+}
+
+// ValidationError 严格模式下指纹校验未通过时返回的错误
+type ValidationError struct {
+	Warnings []Warning
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Warnings))
+	for _, w := range e.Warnings {
+		msgs = append(msgs, w.String())
+	}
+	return "指纹校验未通过: " + strings.Join(msgs, "; ")
+}
+
+// Validate 检查ClientHello规范中会导致握手失败或容易被识别的不一致之处，没有问题时返回空
+func Validate(spec *utls.ClientHelloSpec) []Warning {
+	var (
+		warnings []Warning
+		seen     = make(map[uint16]bool)
+
+		supportedVersions *utls.SupportedVersionsExtension
+		supportedGroups   *utls.SupportedCurvesExtension
+		keyShare          *utls.KeyShareExtension
+		alpn              *utls.ALPNExtension
+		alps              []alpsExtension
+		hasSignatureAlgs  bool
+		hasPSKModes       bool
+		pskIndex          = -1
+	)
+	warn := func(code WarningCode, ext uint16, format string, args ...any) {
+		warnings = append(warnings, Warning{Code: code, Extension: ext, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(spec.CipherSuites) == 0 {
+		warn(WarnEmptyCipherSuites, 0, "没有配置密码套件")
+	}
+
+	for i, ext := range spec.Extensions {
+		switch e := ext.(type) {
+		case *utls.SupportedVersionsExtension:
+			supportedVersions = e
+		case *utls.SupportedCurvesExtension:
+			supportedGroups = e
+		case *utls.KeyShareExtension:
+			keyShare = e
+		case *utls.ALPNExtension:
+			alpn = e
+		case *utls.ApplicationSettingsExtension:
+			alps = append(alps, alpsExtension{extApplicationSettings, e.SupportedProtocols})
+		case *utls.ApplicationSettingsExtensionNew:
+			alps = append(alps, alpsExtension{extApplicationSettingsN, e.SupportedProtocols})
+		case *utls.SignatureAlgorithmsExtension:
+			hasSignatureAlgs = true
+		case *utls.PSKKeyExchangeModesExtension:
+			hasPSKModes = true
+		case utls.PreSharedKeyExtension:
+			pskIndex = i
+		}
+
+		id, ok := extensionID(ext)
+		if !ok || isGREASE(id) {
+			continue
+		}
+		if seen[id] {
+			warn(WarnDuplicateExtension, id, "扩展 %s 重复出现", formatValue(id, extensionNames))
+		}
+		seen[id] = true
+	}
+
+	if pskIndex >= 0 {
+		if pskIndex != len(spec.Extensions)-1 {
+			warn(WarnPSKNotLast, extPreSharedKey, "pre_shared_key 必须是最后一个扩展")
+		}
+		if !hasPSKModes {
+			warn(WarnPSKWithoutModes, extPreSharedKey, "携带 pre_shared_key 时必须同时携带 psk_key_exchange_modes")
+		}
+	}
+
+	// 版本与密码套件
+	var has13Suites, has12Suites, hasECDHESuites bool
+	for _, suite := range spec.CipherSuites {
+		switch {
+		case isGREASE(suite):
+		case suite >= 0x1301 && suite <= 0x1305:
+			has13Suites = true
+		default:
+			has12Suites = true
+			if strings.Contains(cipherSuiteNames[suite], "_ECDHE_") {
+				hasECDHESuites = true
+			}
+		}
+	}
+
+	offers13, offers12 := false, true
+	if supportedVersions != nil {
+		offers12 = false
+		for _, v := range supportedVersions.Versions {
+			switch {
+			case v == utls.VersionTLS13:
+				offers13 = true
+			case !isGREASE(v) && v <= utls.VersionTLS12:
+				offers12 = true
+			}
+		}
+		if spec.TLSVersMax != 0 && spec.TLSVersMax < utls.VersionTLS13 && offers13 {
+			warn(WarnVersionRangeMismatch, extSupportedVersions, "supported_versions 包含 TLS 1.3，但 TLSVersMax 为 %s", formatValue(spec.TLSVersMax, versionNames))
+		}
+	} else if has13Suites {
+		warn(WarnTLS13WithoutSupportedVers, 0, "包含 TLS 1.3 密码套件，但缺少 supported_versions 扩展，实际只会协商 TLS 1.2")
+	}
+
+	if offers13 {
+		if !has13Suites {
+			warn(WarnTLS13WithoutSuites, extSupportedVersions, "supported_versions 包含 TLS 1.3，但没有 TLS 1.3 密码套件")
+		}
+		if keyShare == nil {
+			warn(WarnTLS13WithoutKeyShare, extKeyShare, "支持 TLS 1.3 但缺少 key_share 扩展")
+		}
+		if !hasSignatureAlgs {
+			warn(WarnTLS13WithoutSignatureAlgs, extSignatureAlgorithms, "支持 TLS 1.3 但缺少 signature_algorithms 扩展")
+		}
+	}
+	if offers12 && !has12Suites && len(spec.CipherSuites) > 0 {
+		warn(WarnTLS12WithoutSuites, 0, "支持 TLS 1.2 但没有 TLS 1.2 密码套件")
+	}
+
+	// 密钥共享与曲线
+	if (hasECDHESuites || keyShare != nil) && supportedGroups == nil {
+		warn(WarnECDHEWithoutSupportedGroups, extSupportedGroups, "使用ECDHE密钥交换但缺少 supported_groups 扩展")
+	}
+	if keyShare != nil && supportedGroups != nil {
+		for _, ks := range keyShare.KeyShares {
+			if isGREASE(uint16(ks.Group)) {
+				continue
+			}
+			if !slices.Contains(supportedGroups.Curves, ks.Group) {
+				warn(WarnKeyShareNotInGroups, extKeyShare, "key_share 中的 %s 不在 supported_groups 中", formatValue(uint16(ks.Group), groupNames))
+			}
+		}
+	}
+
+	// ALPN 与 ALPS (新旧两个扩展编号规则相同)
+	for _, a := range alps {
+		name := formatValue(a.id, extensionNames)
+		if alpn == nil {
+			warn(WarnALPSWithoutALPN, a.id, "携带 %s 但缺少 ALPN 扩展", name)
+			continue
+		}
+		for _, proto := range a.protocols {
+			if !slices.Contains(alpn.AlpnProtocols, proto) {
+				warn(WarnALPSProtocolNotInALPN, a.id, "%s 中的协议 %s 不在 ALPN 中", name, proto)
+			}
+		}
+	}
+
+	return warnings
+}
+
+// alpsExtension ALPS扩展的编号与协议列表
+type alpsExtension struct {
+	id        uint16
+	protocols []string
+}
+
+// extensionID 返回扩展编号，GREASE扩展和无法识别的扩展返回 false
+func extensionID(ext utls.TLSExtension) (uint16, bool) {
+	switch e := ext.(type) {
+	case *utls.UtlsGREASEExtension:
+		return 0, false
+	case *utls.SNIExtension:
+		return extServerName, true
+	case *utls.UtlsPaddingExtension:
+		return extPadding, true
+	case utls.PreSharedKeyExtension:
+		return extPreSharedKey, true
+	case *utls.GenericExtension:
+		return e.Id, true
+	}
+
+	raw := make([]byte, ext.Len())
+	if len(raw) < 4 {
+		return 0, false
+	}
+	if n, _ := ext.Read(raw); n < 2 {
+		return 0, false
+	}
+	return uint16(raw[0])<<8 | uint16(raw[1]), true
+}
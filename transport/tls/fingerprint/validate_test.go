@@ -0,0 +1,214 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package fingerprint
+
+import (
+	"slices"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// validSpec 返回没有校验问题的最小 TLS 1.2/1.3 规范，各测试用例在其基础上制造一处问题
+func validSpec() *utls.ClientHelloSpec {
+	return &utls.ClientHelloSpec{
+		CipherSuites: []uint16{
+			utls.GREASE_PLACEHOLDER,
+			utls.TLS_AES_128_GCM_SHA256,
+			utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		},
+		CompressionMethods: []byte{0},
+		Extensions: []utls.TLSExtension{
+			&utls.UtlsGREASEExtension{},
+			&utls.SNIExtension{},
+			&utls.SupportedCurvesExtension{Curves: []utls.CurveID{utls.GREASE_PLACEHOLDER, utls.X25519, utls.CurveP256}},
+			&utls.SupportedPointsExtension{SupportedPoints: []byte{0}},
+			&utls.SignatureAlgorithmsExtension{SupportedSignatureAlgorithms: []utls.SignatureScheme{utls.ECDSAWithP256AndSHA256}},
+			&utls.ALPNExtension{AlpnProtocols: []string{"h2", "http/1.1"}},
+			&utls.KeyShareExtension{KeyShares: []utls.KeyShare{{Group: utls.GREASE_PLACEHOLDER, Data: []byte{0}}, {Group: utls.X25519}}},
+			&utls.PSKKeyExchangeModesExtension{Modes: []uint8{utls.PskModeDHE}},
+			&utls.SupportedVersionsExtension{Versions: []uint16{utls.GREASE_PLACEHOLDER, utls.VersionTLS13, utls.VersionTLS12}},
+			&utls.UtlsGREASEExtension{},
+		},
+	}
+}
+
+// removeExtension 删除规范中类型为 T 的扩展
+func removeExtension[T utls.TLSExtension](spec *utls.ClientHelloSpec) {
+	spec.Extensions = slices.DeleteFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+		_, ok := ext.(T)
+		return ok
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(spec *utls.ClientHelloSpec)
+		want    WarningCode
+		wantExt uint16
+	}{
+		{
+			name:   "没有密码套件",
+			mutate: func(spec *utls.ClientHelloSpec) { spec.CipherSuites = nil },
+			want:   WarnEmptyCipherSuites,
+		},
+		{
+			name: "扩展重复",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions, &utls.SNIExtension{})
+			},
+			want: WarnDuplicateExtension, wantExt: extServerName,
+		},
+		{
+			name: "pre_shared_key不在末尾",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions, defaultFakePSK(), &utls.ExtendedMasterSecretExtension{})
+			},
+			want: WarnPSKNotLast, wantExt: extPreSharedKey,
+		},
+		{
+			name: "pre_shared_key缺少psk_key_exchange_modes",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				removeExtension[*utls.PSKKeyExchangeModesExtension](spec)
+				spec.Extensions = append(spec.Extensions, defaultFakePSK())
+			},
+			want: WarnPSKWithoutModes, wantExt: extPreSharedKey,
+		},
+		{
+			name:   "TLS 1.3密码套件缺少supported_versions",
+			mutate: removeExtension[*utls.SupportedVersionsExtension],
+			want:   WarnTLS13WithoutSupportedVers,
+		},
+		{
+			name: "supported_versions包含TLS 1.3但没有TLS 1.3密码套件",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.CipherSuites = []uint16{utls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+			},
+			want: WarnTLS13WithoutSuites, wantExt: extSupportedVersions,
+		},
+		{
+			name: "supported_versions包含TLS 1.2但没有TLS 1.2密码套件",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.CipherSuites = []uint16{utls.TLS_AES_128_GCM_SHA256}
+			},
+			want: WarnTLS12WithoutSuites,
+		},
+		{
+			name:   "TLSVersMax低于supported_versions",
+			mutate: func(spec *utls.ClientHelloSpec) { spec.TLSVersMax = utls.VersionTLS12 },
+			want:   WarnVersionRangeMismatch, wantExt: extSupportedVersions,
+		},
+		{
+			name:   "TLS 1.3缺少key_share",
+			mutate: removeExtension[*utls.KeyShareExtension],
+			want:   WarnTLS13WithoutKeyShare, wantExt: extKeyShare,
+		},
+		{
+			name:   "TLS 1.3缺少signature_algorithms",
+			mutate: removeExtension[*utls.SignatureAlgorithmsExtension],
+			want:   WarnTLS13WithoutSignatureAlgs, wantExt: extSignatureAlgorithms,
+		},
+		{
+			name: "key_share的曲线不在supported_groups中",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				removeExtension[*utls.KeyShareExtension](spec)
+				spec.Extensions = append(spec.Extensions, &utls.KeyShareExtension{KeyShares: []utls.KeyShare{{Group: utls.CurveP384}}})
+			},
+			want: WarnKeyShareNotInGroups, wantExt: extKeyShare,
+		},
+		{
+			name:   "ECDHE缺少supported_groups",
+			mutate: removeExtension[*utls.SupportedCurvesExtension],
+			want:   WarnECDHEWithoutSupportedGroups, wantExt: extSupportedGroups,
+		},
+		{
+			name: "ALPS缺少ALPN",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				removeExtension[*utls.ALPNExtension](spec)
+				spec.Extensions = append(spec.Extensions, &utls.ApplicationSettingsExtension{SupportedProtocols: []string{"h2"}})
+			},
+			want: WarnALPSWithoutALPN, wantExt: extApplicationSettings,
+		},
+		{
+			name: "ALPS协议不在ALPN中",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions, &utls.ApplicationSettingsExtension{SupportedProtocols: []string{"h3"}})
+			},
+			want: WarnALPSProtocolNotInALPN, wantExt: extApplicationSettings,
+		},
+		{
+			name: "新ALPS缺少ALPN",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				removeExtension[*utls.ALPNExtension](spec)
+				spec.Extensions = append(spec.Extensions, &utls.ApplicationSettingsExtensionNew{SupportedProtocols: []string{"h2"}})
+			},
+			want: WarnALPSWithoutALPN, wantExt: extApplicationSettingsN,
+		},
+		{
+			name: "新ALPS协议不在ALPN中",
+			mutate: func(spec *utls.ClientHelloSpec) {
+				spec.Extensions = append(spec.Extensions, &utls.ApplicationSettingsExtensionNew{SupportedProtocols: []string{"h2", "h3"}})
+			},
+			want: WarnALPSProtocolNotInALPN, wantExt: extApplicationSettingsN,
+		},
+	}
+
+	if warnings := Validate(validSpec()); len(warnings) > 0 {
+		t.Fatalf("Validate(validSpec()) = %v, want 无问题", warnings)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := validSpec()
+			tt.mutate(spec)
+			warnings := Validate(spec)
+			i := slices.IndexFunc(warnings, func(w Warning) bool { return w.Code == tt.want })
+			if i < 0 {
+				t.Fatalf("Validate() = %v, 缺少 %s", warnings, tt.want)
+			}
+			if warnings[i].Extension != tt.wantExt {
+				t.Errorf("%s 的扩展编号 = %d, want %d", tt.want, warnings[i].Extension, tt.wantExt)
+			}
+			if warnings[i].Message == "" {
+				t.Errorf("%s 缺少说明", tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	for _, name := range ProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			sf, _ := Profile(name)
+			if warnings := Validate(sf()); len(warnings) > 0 {
+				t.Errorf("Validate() = %v, 内置指纹不应有校验问题", warnings)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := &ValidationError{Warnings: []Warning{
+		{Code: WarnPSKNotLast, Message: "pre_shared_key 必须是最后一个扩展"},
+		{Code: WarnEmptyCipherSuites, Message: "没有配置密码套件"},
+	}}
+	want := "指纹校验未通过: [psk_not_last] pre_shared_key 必须是最后一个扩展; [empty_cipher_suites] 没有配置密码套件"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
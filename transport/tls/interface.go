@@ -20,8 +20,30 @@ package tls
 import (
 	"context"
 	"net"
+	"net/url"
+
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
 )
 
 type ITLSDialer interface {
-	DialTLS(ctx context.Context, network, addr string) (net.Conn, error)
+	DialTLS(ctx context.Context, network, addr string) (IFingerConn, error)
+}
+
+// IFingerConn 完成指纹握手的TLS连接。自定义 ITLSDialer 包装连接时嵌入该接口即可保留这些方法。
+// RemoteAddr 返回实际建立TCP连接的地址: 直连时为 Happy Eyeballs 胜出的地址，通过代理连接时为第一跳代理的地址
+type IFingerConn interface {
+	net.Conn
+	// ConnectionState 返回握手后的TLS连接状态
+	ConnectionState() utls.ConnectionState
+	// NegotiatedProtocol 返回ALPN协商结果，未协商时为空
+	NegotiatedProtocol() string
+	// Spec 返回握手实际使用的ClientHello规范
+	Spec() *utls.ClientHelloSpec
+	// ClientHello 返回实际发送的ClientHello报文 (不含记录层头部)
+	ClientHello() []byte
+	// Fingerprint 返回根据实际发送的ClientHello计算的 JA3/JA4 指纹
+	Fingerprint() (*fingerprint.Fingerprint, error)
+	// ProxyPath 返回连接经过的代理，直连时为空。返回的是去掉认证信息的副本，修改不影响连接
+	ProxyPath() []*url.URL
 }
@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// hungServer 接受一个连接后不做任何响应，只读取数据直到连接被对端关闭，closed 在连接关闭后关闭
+func hungServer(t *testing.T) (addr string, closed <-chan struct{}) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			close(done)
+			return
+		}
+		_, _ = io.Copy(io.Discard, conn)
+		conn.Close()
+		close(done)
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr().String(), done
+}
+
+// waitGoroutines 等待协程数回落到 baseline，超时后输出所有协程的堆栈
+func waitGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("协程数 %d 未回落到 %d:\n%s", runtime.NumGoroutine(), baseline, buf)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDialHungServerNoLeak(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string // 为空时直连，否则通过该协议的代理连接
+		cancel bool   // true 时主动取消，否则等待 ctx 超时
+	}{
+		{name: "TLS握手/超时"},
+		{name: "TLS握手/取消", cancel: true},
+		{name: "HTTP代理协商/超时", scheme: "http"},
+		{name: "HTTP代理协商/取消", scheme: "http", cancel: true},
+		{name: "SOCKS5代理协商/超时", scheme: "socks5"},
+		{name: "SOCKS5代理协商/取消", scheme: "socks5", cancel: true},
+		{name: "HTTPS代理握手/超时", scheme: "https"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, closed := hungServer(t)
+			baseline := runtime.NumGoroutine()
+
+			opts := []Option{
+				WithLogger(logging.NewFakeLogger()),
+				WithInsecureSkipVerify(),
+				WithProxyInsecureSkipVerify(),
+			}
+			target := addr
+			if tt.scheme != "" {
+				opts = append(opts, WithUpstreamProxy(&url.URL{Scheme: tt.scheme, Host: addr}))
+				target = "127.0.0.1:443"
+			}
+			dialer, err := NewTLSDialerE(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			want := context.DeadlineExceeded
+			if tt.cancel {
+				ctx, cancel = context.WithCancel(context.Background())
+				defer cancel()
+				time.AfterFunc(100*time.Millisecond, cancel)
+				want = context.Canceled
+			}
+
+			start := time.Now()
+			conn, err := dialer.DialTLS(ctx, "tcp", target)
+			if err == nil {
+				conn.Close()
+				t.Fatal("DialTLS() 连接无响应的服务器应返回错误")
+			}
+			if !errors.Is(err, want) {
+				t.Errorf("DialTLS() error = %v, want %v", err, want)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("DialTLS() 耗时 %v，未在 ctx 结束后立即返回", elapsed)
+			}
+
+			select {
+			case <-closed:
+			case <-time.After(2 * time.Second):
+				t.Fatal("ctx 结束后连接未被关闭")
+			}
+			waitGoroutines(t, baseline)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+	utls "github.com/refraction-networking/utls"
+)
+
+// newClientCert 生成由 ca 签发、用于客户端认证的证书
+func newClientCert(t *testing.T, name string, ca *testCert) utls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveMTLS 启动要求客户端证书的HTTPS服务器，响应内容为客户端证书的CN
+func serveMTLS(t *testing.T, clientCA *testCert) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDialClientCertificates(t *testing.T) {
+	clientCA := newTestCA(t)
+	otherCA := newTestCA(t)
+	static := newClientCert(t, "static", clientCA)
+	perHost := newClientCert(t, "per-host", clientCA)
+	dynamic := newClientCert(t, "dynamic", clientCA)
+	untrusted := newClientCert(t, "untrusted", otherCA)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{name: "静态证书", opts: []Option{WithClientCertificates(static)}, want: "static"},
+		{
+			name: "按主机选择证书",
+			opts: []Option{WithClientCertificates(static), WithHostClientCertificates("Example.COM", perHost)},
+			want: "per-host",
+		},
+		{
+			name: "其他主机使用静态证书",
+			opts: []Option{WithClientCertificates(static), WithHostClientCertificates("other.example", perHost)},
+			want: "static",
+		},
+		{
+			name: "回调选择证书",
+			opts: []Option{
+				WithClientCertificates(static),
+				WithGetClientCertificate(func(info *utls.CertificateRequestInfo) (*utls.Certificate, error) {
+					if len(info.AcceptableCAs) == 0 {
+						t.Error("CertificateRequestInfo 缺少服务器接受的CA")
+					}
+					return &dynamic, nil
+				}),
+			},
+			want: "dynamic",
+		},
+		{name: "没有证书", wantErr: true},
+		{name: "证书不受信任", opts: []Option{WithClientCertificates(untrusted)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveMTLS(t, clientCA)
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+
+			// httptest 的服务器证书包含 example.com，按主机选择证书时以校验主机名而不是SNI为准
+			opts := append([]Option{
+				WithLogger(logging.NewFakeLogger()),
+				WithRootCAs(roots),
+				WithServerName("origin.example"),
+				WithVerifyServerName("example.com"),
+				WithALPN("http/1.1"),
+			}, tt.opts...)
+			dialer, err := NewTLSDialerE(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: &http.Transport{
+				DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return dialer.DialTLS(ctx, network, addr)
+				},
+			}}
+			defer client.CloseIdleConnections()
+
+			resp, err := client.Get(srv.URL)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("服务器要求客户端证书时请求应失败")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("服务器收到的客户端证书 = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
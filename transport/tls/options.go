@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/aberstone/fingertls/transport/proxy_connector"
+)
+
+var (
+	// ErrUnsupportedProxyScheme 代理地址使用了未通过 proxy_connector.Register 注册的协议
+	ErrUnsupportedProxyScheme = errors.New("不支持的代理协议")
+	// ErrMissingProxyPort 代理地址缺少端口
+	ErrMissingProxyPort = errors.New("代理地址缺少端口")
+	// ErrNilSpecFactory 未设置指纹
+	ErrNilSpecFactory = errors.New("指纹工厂为空")
+	// ErrNegativeTimeout 超时时间为负数
+	ErrNegativeTimeout = errors.New("超时时间不能为负数")
+)
+
+// OptionError 配置校验失败，Option 为出错的配置项，Err 可通过 errors.Is 与 Err* 错误比较
+type OptionError struct {
+	Option string
+	Err    error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("无效的配置 %s: %v", e.Option, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// NewOptions 在默认配置上应用 opts，可配合 Validate 在加载配置时提前校验
+func NewOptions(opts ...Option) *Options {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Validate 校验配置，返回所有问题，每个问题均为 *OptionError
+func (o *Options) Validate() error {
+	var errs []error
+	invalid := func(option string, err error) {
+		errs = append(errs, &OptionError{Option: option, Err: err})
+	}
+
+	if o.logger == nil {
+		invalid("WithLogger", errors.New("日志记录器为空"))
+	}
+	if o.sf == nil && o.pool == nil {
+		invalid("WithSpecFactory", ErrNilSpecFactory)
+	}
+	// HTTPS 代理握手未单独设置指纹时使用 WithSpecFactory 的指纹
+	if o.proxySF == nil && o.sf == nil && slices.ContainsFunc(o.proxyChain, func(u *url.URL) bool {
+		return u != nil && u.Scheme == string(proxy_connector.ProxySchemeHTTPS)
+	}) {
+		invalid("WithProxySpecFactory", ErrNilSpecFactory)
+	}
+
+	timeouts := []struct {
+		option  string
+		timeout time.Duration
+	}{
+		{"WithTimeout", o.timeout},
+		{"WithProxyTimeout", o.proxyTimeout},
+		{"WithHandshakeTimeout", o.handshakeTimeout},
+		{"WithFallbackDelay", o.fallbackDelay},
+	}
+	for _, t := range timeouts {
+		if t.timeout < 0 {
+			invalid(t.option, fmt.Errorf("%w: %s", ErrNegativeTimeout, t.timeout))
+		}
+	}
+
+	for i, hop := range o.proxyChain {
+		if err := validateProxyURL(hop); err != nil {
+			invalid(fmt.Sprintf("WithProxyChain[%d]", i), err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateProxyURL 校验代理地址的协议、端口和单跳超时参数
+func validateProxyURL(u *url.URL) error {
+	if u == nil {
+		return errors.New("代理地址为空")
+	}
+	if _, ok := proxy_connector.Lookup(u.Scheme); !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedProxyScheme, u.Scheme)
+	}
+	if u.Port() == "" {
+		return fmt.Errorf("%w: %s", ErrMissingProxyPort, u.Redacted())
+	}
+	if v := u.Query().Get("timeout"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("无效的超时时间 %q: %w", v, err)
+		}
+		if timeout < 0 {
+			return fmt.Errorf("%w: %s", ErrNegativeTimeout, timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// proxyURL 解析测试用的代理地址
+func proxyURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       func(t *testing.T) []Option
+		wantErr    error  // 为空时只检查 *OptionError
+		wantOption string // 为空时校验应通过
+		wantMsg    string
+	}{
+		{name: "默认配置", opts: func(t *testing.T) []Option { return nil }},
+		{
+			name: "有效的代理链",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithProxyChain([]*url.URL{
+					proxyURL(t, "http://proxy1.example:8080?timeout=5s"),
+					proxyURL(t, "https://proxy2.example:8443"),
+					proxyURL(t, "SOCKS5://proxy3.example:1080"),
+				})}
+			},
+		},
+		{
+			name: "不支持的代理协议",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithUpstreamProxy(proxyURL(t, "ftp://proxy.example:21"))}
+			},
+			wantErr: ErrUnsupportedProxyScheme, wantOption: "WithProxyChain[0]",
+		},
+		{
+			name: "代理地址缺少端口",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithProxyChain([]*url.URL{
+					proxyURL(t, "http://proxy1.example:8080"),
+					proxyURL(t, "socks5://proxy2.example"),
+				})}
+			},
+			wantErr: ErrMissingProxyPort, wantOption: "WithProxyChain[1]",
+		},
+		{
+			name:    "指纹工厂为空",
+			opts:    func(t *testing.T) []Option { return []Option{WithSpecFactory(nil)} },
+			wantErr: ErrNilSpecFactory, wantOption: "WithSpecFactory",
+		},
+		{
+			name:    "负数连接超时",
+			opts:    func(t *testing.T) []Option { return []Option{WithTimeout(-time.Second)} },
+			wantErr: ErrNegativeTimeout, wantOption: "WithTimeout",
+		},
+		{
+			name:    "负数代理超时",
+			opts:    func(t *testing.T) []Option { return []Option{WithProxyTimeout(-time.Second)} },
+			wantErr: ErrNegativeTimeout, wantOption: "WithProxyTimeout",
+		},
+		{
+			name:    "负数握手超时",
+			opts:    func(t *testing.T) []Option { return []Option{WithHandshakeTimeout(-time.Second)} },
+			wantErr: ErrNegativeTimeout, wantOption: "WithHandshakeTimeout",
+		},
+		{
+			name:    "负数 Happy Eyeballs 延迟",
+			opts:    func(t *testing.T) []Option { return []Option{WithFallbackDelay(-time.Millisecond)} },
+			wantErr: ErrNegativeTimeout, wantOption: "WithFallbackDelay",
+		},
+		{
+			name: "代理地址中的负数超时",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithUpstreamProxy(proxyURL(t, "http://proxy.example:8080?timeout=-5s"))}
+			},
+			wantErr: ErrNegativeTimeout, wantOption: "WithProxyChain[0]",
+		},
+		{
+			name: "代理地址中无效的超时",
+			opts: func(t *testing.T) []Option {
+				return []Option{WithUpstreamProxy(proxyURL(t, "http://proxy.example:8080?timeout=soon"))}
+			},
+			wantOption: "WithProxyChain[0]", wantMsg: `无效的超时时间 "soon"`,
+		},
+		{
+			name:       "日志记录器为空",
+			opts:       func(t *testing.T) []Option { return []Option{WithLogger(nil)} },
+			wantOption: "WithLogger",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewOptions(tt.opts(t)...).Validate()
+			if tt.wantOption == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() 应返回错误")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.wantErr)
+			}
+			var optErr *OptionError
+			if !errors.As(err, &optErr) {
+				t.Fatalf("Validate() error = %v, want *OptionError", err)
+			}
+			if optErr.Option != tt.wantOption {
+				t.Errorf("OptionError.Option = %q, want %q", optErr.Option, tt.wantOption)
+			}
+			if tt.wantMsg != "" && !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Validate() error = %v, 缺少 %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestOptionsValidateJoinsErrors(t *testing.T) {
+	err := NewOptions(
+		WithSpecFactory(nil),
+		WithTimeout(-time.Second),
+		WithProxyChain([]*url.URL{
+			proxyURL(t, "ftp://proxy1.example:21"),
+			proxyURL(t, "https://proxy2.example"),
+		}),
+	).Validate()
+
+	// 每个问题都可以通过 errors.Is 找到
+	for _, want := range []error{ErrNilSpecFactory, ErrNegativeTimeout, ErrUnsupportedProxyScheme, ErrMissingProxyPort} {
+		if !errors.Is(err, want) {
+			t.Errorf("errors.Is(err, %v) = false", want)
+		}
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("Validate() error = %T, want errors.Join 的结果", err)
+	}
+	var options []string
+	for _, e := range joined.Unwrap() {
+		var optErr *OptionError
+		if !errors.As(e, &optErr) {
+			t.Errorf("%v 不是 *OptionError", e)
+			continue
+		}
+		options = append(options, optErr.Option)
+	}
+	// HTTPS 代理未单独设置指纹时同样需要 WithSpecFactory
+	want := []string{"WithSpecFactory", "WithProxySpecFactory", "WithTimeout", "WithProxyChain[0]", "WithProxyChain[1]"}
+	if strings.Join(options, ",") != strings.Join(want, ",") {
+		t.Errorf("出错的配置项 = %v, want %v", options, want)
+	}
+}
+
+func TestNewTLSDialerE(t *testing.T) {
+	dialer, err := NewTLSDialerE(WithLogger(logging.NewFakeLogger()), WithHandshakeTimeout(-time.Second))
+	if dialer != nil {
+		t.Error("NewTLSDialerE() 配置无效时应返回空的拨号器")
+	}
+	if !errors.Is(err, ErrNegativeTimeout) {
+		t.Errorf("NewTLSDialerE() error = %v, want %v", err, ErrNegativeTimeout)
+	}
+
+	if _, err := NewTLSDialerE(WithLogger(logging.NewFakeLogger())); err != nil {
+		t.Errorf("NewTLSDialerE() 默认配置 error = %v", err)
+	}
+}
+
+func TestNewTLSDialerPanics(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, ErrUnsupportedProxyScheme) {
+			t.Errorf("NewTLSDialer() panic = %v, want 包含 %v 的错误", err, ErrUnsupportedProxyScheme)
+		}
+	}()
+	NewTLSDialer(WithLogger(logging.NewFakeLogger()), WithUpstreamProxy(&url.URL{Scheme: "ftp", Host: "proxy.example:21"}))
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PinMismatchError 校验后的服务器证书链中没有任何公钥与固定的 SPKI 哈希匹配
+type PinMismatchError struct {
+	Host string
+	// Presented 参与匹配的各证书的 SPKI SHA-256 哈希 (base64)
+	Presented []string
+}
+
+func (e *PinMismatchError) Error() string {
+	return fmt.Sprintf("%s 的证书公钥与固定值不匹配, 实际: %s", e.Host, strings.Join(e.Presented, ", "))
+}
+
+// SPKIHash 计算证书公钥 (SubjectPublicKeyInfo) 的 SHA-256 哈希，返回 base64 编码，可直接用于 WithPinnedKeys
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// normalizePin 去掉 HPKP 风格的 "sha256/" 前缀
+func normalizePin(pin string) string {
+	return strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+}
+
+// verifyPeerFunc 证书校验回调，与 utls.Config.VerifyPeerCertificate 的签名一致
+type verifyPeerFunc func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error
+
+// pinVerifier 在证书校验回调中执行公钥固定校验，通过后再调用 next；未对该主机配置固定值时直接返回 next。
+// 证书经过校验时只匹配 x509 校验得到的证书链，服务器额外发送的证书不参与匹配，
+// 避免中间人在自己的证书链后附加被固定的证书绕过校验；跳过证书校验时只匹配服务器的叶子证书
+func (d *BaseTLSDialer) pinVerifier(host string, next verifyPeerFunc) verifyPeerFunc {
+	pins, ok := d.opts.pins[strings.ToLower(host)]
+	if !ok {
+		return next
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := d.checkPins(host, pins, rawCerts, verifiedChains); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(rawCerts, verifiedChains)
+		}
+		return nil
+	}
+}
+
+// checkPins 校验证书公钥是否与固定值匹配，仅报告模式下失败时只记录日志
+func (d *BaseTLSDialer) checkPins(host string, pins []string, rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	var certs []*x509.Certificate
+	if d.opts.insecureSkipVerify {
+		if len(rawCerts) > 0 {
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return fmt.Errorf("解析服务器证书失败: %w", err)
+			}
+			certs = append(certs, leaf)
+		}
+	} else {
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+	}
+
+	presented := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		hash := SPKIHash(cert)
+		if slices.Contains(pins, hash) {
+			return nil
+		}
+		if !slices.Contains(presented, hash) {
+			presented = append(presented, hash)
+		}
+	}
+
+	err := &PinMismatchError{Host: host, Presented: presented}
+	if d.opts.pinReportOnly {
+		d.opts.logger.Warn(fmt.Sprintf("[TLS] 证书公钥固定校验失败 (仅报告): %v", err))
+		return nil
+	}
+	d.opts.logger.Error("证书公钥固定校验失败", err)
+	return err
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+)
+
+// testCert 测试用证书及其私钥
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert 生成证书，parent 为空时生成自签名CA证书
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.DNSNames = []string{name}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// newTestCA 生成自签名CA证书
+func newTestCA(t *testing.T) *testCert {
+	t.Helper()
+	return newTestCert(t, "Test CA", nil)
+}
+
+// serveTLS 在本地启动TLS服务器，发送 leaf 及 extra 组成的证书链，测试结束时关闭
+func serveTLS(t *testing.T, leaf *testCert, extra ...*testCert) string {
+	t.Helper()
+	return serveTLSAt(t, "127.0.0.1:0", leaf, extra...)
+}
+
+// serveTLSAt 在指定地址启动TLS服务器，返回实际监听的地址
+func serveTLSAt(t *testing.T, addr string, leaf *testCert, extra ...*testCert) string {
+	t.Helper()
+	chain := [][]byte{leaf.cert.Raw}
+	for _, c := range extra {
+		chain = append(chain, c.cert.Raw)
+	}
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: leaf.key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestPinnedKeys(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "origin.example", ca)
+	// 中间人持有受信任CA签发的证书，并在证书链后附加真实服务器被固定的证书
+	mitmCA := newTestCA(t)
+	mitmLeaf := newTestCert(t, "origin.example", mitmCA)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	roots.AddCert(mitmCA.cert)
+
+	direct := serveTLS(t, leaf)
+	mitm := serveTLS(t, mitmLeaf, leaf)
+
+	verified := func(ctx context.Context) context.Context {
+		return ContextWithServerName(ctx, "origin.example")
+	}
+	// SNI与校验主机名不一致时走手动校验
+	fronted := func(ctx context.Context) context.Context {
+		return ContextWithVerifyServerName(ContextWithServerName(ctx, "front.example"), "origin.example")
+	}
+
+	tests := []struct {
+		name     string
+		addr     string
+		pin      *testCert
+		ctx      func(context.Context) context.Context
+		insecure bool
+		wantErr  bool
+	}{
+		{name: "叶子证书匹配", addr: direct, pin: leaf, ctx: verified},
+		{name: "CA证书匹配", addr: direct, pin: ca, ctx: verified},
+		{name: "不匹配", addr: direct, pin: mitmLeaf, ctx: verified, wantErr: true},
+		{name: "附加被固定的证书", addr: mitm, pin: leaf, ctx: verified, wantErr: true},
+		{name: "SNI不一致/叶子证书匹配", addr: direct, pin: leaf, ctx: fronted},
+		{name: "SNI不一致/CA证书匹配", addr: direct, pin: ca, ctx: fronted},
+		{name: "SNI不一致/附加被固定的证书", addr: mitm, pin: leaf, ctx: fronted, wantErr: true},
+		{name: "跳过校验/叶子证书匹配", addr: direct, pin: leaf, ctx: verified, insecure: true},
+		// 跳过校验时无法确认证书链的签发关系，只匹配叶子证书
+		{name: "跳过校验/CA证书不参与匹配", addr: direct, pin: ca, ctx: verified, insecure: true, wantErr: true},
+		{name: "跳过校验/附加被固定的证书", addr: mitm, pin: leaf, ctx: verified, insecure: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{
+				WithLogger(logging.NewFakeLogger()),
+				WithRootCAs(roots),
+				WithPinnedKeys("origin.example", "sha256/"+SPKIHash(tt.pin.cert)),
+			}
+			if tt.insecure {
+				opts = append(opts, WithInsecureSkipVerify())
+			}
+			dialer, err := NewTLSDialerE(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := dialer.DialTLS(tt.ctx(context.Background()), "tcp", tt.addr)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("DialTLS() error = %v", err)
+				}
+				conn.Close()
+				return
+			}
+			var pinErr *PinMismatchError
+			if !errors.As(err, &pinErr) {
+				if conn != nil {
+					conn.Close()
+				}
+				t.Fatalf("DialTLS() error = %v, want *PinMismatchError", err)
+			}
+		})
+	}
+}
+
+func TestPinReportOnly(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "origin.example", ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithRootCAs(roots),
+		WithPinnedKeys("origin.example", SPKIHash(newTestCA(t).cert)),
+		WithPinReportOnly(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.DialTLS(ContextWithServerName(context.Background(), "origin.example"), "tcp", serveTLS(t, leaf))
+	if err != nil {
+		t.Fatalf("仅报告模式不应中断连接: %v", err)
+	}
+	conn.Close()
+}
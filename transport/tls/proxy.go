@@ -23,21 +23,105 @@ import (
 	"net"
 
 	"github.com/aberstone/fingertls/transport/proxy_connector"
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
+)
+
+// ProxyResolvePolicy 通过代理连接时目标主机名的解析方式
+type ProxyResolvePolicy int
+
+const (
+	// ProxyResolveRemote 将主机名发送给代理服务器，由代理服务器解析 (默认)
+	ProxyResolveRemote ProxyResolvePolicy = iota
+	// ProxyResolveLocal 使用拨号器的解析器在本地解析，只将IP地址发送给代理服务器
+	ProxyResolveLocal
 )
 
 type ProxyTLSDialer struct {
 	*BaseTLSDialer
-	connector proxy_connector.ProxyConnector
+	connector *proxy_connector.ChainProxyConnector
 }
 
-func (d *ProxyTLSDialer) DialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+func (d *ProxyTLSDialer) DialTLS(ctx context.Context, network, addr string) (IFingerConn, error) {
 	d.opts.logger.Info(fmt.Sprintf("[TLS] 通过代理连接到 %s", addr))
 
-	proxyConn, err := d.connector.Connect(ctx, d.opts.upstreamProxy, addr)
+	target := addr
+	if d.opts.proxyResolve == ProxyResolveLocal {
+		resolved, err := d.resolveTarget(ctx, addr)
+		if err != nil {
+			d.opts.logger.Error(fmt.Sprintf("解析 %s 失败", addr), err)
+			return nil, err
+		}
+		target = resolved
+	}
+
+	proxyConn, err := d.connector.ConnectChain(ctx, d.opts.proxyChain, target)
 	if err != nil {
 		d.opts.logger.Error(fmt.Sprintf("代理连接到 %s 失败", addr), err)
 		return nil, err
 	}
 
-	return d.handshakeTLS(ctx, proxyConn, extractServerName(addr))
+	tlsConn, err := d.handshakeTLS(ctx, proxyConn, extractServerName(addr))
+	if err != nil {
+		return nil, err
+	}
+	tlsConn.proxyPath = stripProxyUserinfo(d.opts.proxyChain)
+	return tlsConn, nil
+}
+
+// resolveTarget 在本地解析目标地址，按地址族偏好返回第一个地址
+func (d *ProxyTLSDialer) resolveTarget(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	ips, err := d.lookupIP(ctx, "tcp", host)
+	if err != nil {
+		return "", err
+	}
+	addrs := sortAddrs(ips, d.opts.familyPreference, port)
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("%s 没有可用的地址", host)
+	}
+	return addrs[0], nil
+}
+
+// proxyTLSWrapper 返回与HTTPS代理服务器进行TLS握手的函数。
+// 代理握手使用独立的证书校验配置和指纹，并且不受单次拨号中 SNI、ALPN 设置的影响
+func proxyTLSWrapper(options *Options) proxy_connector.TLSWrapFunc {
+	sf := options.proxySF
+	if sf == nil {
+		sf = options.sf
+	}
+	proxyDialer := &BaseTLSDialer{opts: &Options{
+		logger:             options.logger,
+		sf:                 proxySpecFactory(sf),
+		handshakeTimeout:   options.handshakeTimeout,
+		insecureSkipVerify: options.proxyInsecureSkipVerify,
+		rootCAs:            options.proxyRootCAs,
+	}}
+
+	return func(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+		ctx = context.WithValue(ctx, serverNameKey{}, serverNameConfig{})
+		ctx = context.WithValue(ctx, alpnKey{}, nil)
+		tlsConn, err := proxyDialer.handshakeTLS(ctx, conn, serverName)
+		if err != nil {
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// proxySpecFactory CONNECT 只能在 HTTP/1.1 上进行，将指纹中的ALPN改为 http/1.1
+func proxySpecFactory(sf fingerprint.SpecFactory) fingerprint.SpecFactory {
+	return func() *utls.ClientHelloSpec {
+		spec := sf()
+		for _, ext := range spec.Extensions {
+			if alpn, ok := ext.(*utls.ALPNExtension); ok {
+				rewriteALPN(spec, alpn, []string{"http/1.1"})
+				break
+			}
+		}
+		return spec
+	}
 }
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/resolver"
+)
+
+// serveHTTPSProxy 启动使用 leaf 证书的HTTPS CONNECT代理，将每次握手收到的ClientHello发送到返回的通道
+func serveHTTPSProxy(t *testing.T, leaf *testCert) (*httptest.Server, <-chan clientHello) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "只支持CONNECT", http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+			return
+		}
+		go func() {
+			io.Copy(target, buf)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+	}))
+
+	hellos := make(chan clientHello, 4)
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.cert.Raw}, PrivateKey: leaf.key}},
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hellos <- clientHello{
+				ServerName: info.ServerName,
+				ALPN:       slices.Clone(info.SupportedProtos),
+				Extensions: slices.Clone(info.Extensions),
+			}
+			return nil, nil
+		},
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, hellos
+}
+
+func TestDialHTTPSProxy(t *testing.T) {
+	targetCA, proxyCA := newTestCA(t), newTestCA(t)
+	targetRoots, proxyRoots := x509.NewCertPool(), x509.NewCertPool()
+	targetRoots.AddCert(targetCA.cert)
+	proxyRoots.AddCert(proxyCA.cert)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool // 代理服务器证书校验失败
+	}{
+		{name: "WithProxyRootCAs", opts: []Option{WithRootCAs(targetRoots), WithProxyRootCAs(proxyRoots)}},
+		{name: "WithProxyInsecureSkipVerify", opts: []Option{WithRootCAs(targetRoots), WithProxyInsecureSkipVerify()}},
+		{
+			name:    "WithRootCAs不作用于代理",
+			opts:    []Option{WithRootCAs(proxyRoots)},
+			wantErr: true,
+		},
+		{
+			name:    "代理根证书不匹配",
+			opts:    []Option{WithRootCAs(targetRoots), WithProxyRootCAs(targetRoots)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targetAddr, targetHellos := serveHello(t, newTestCert(t, "origin.example", targetCA), func(config *tls.Config) {
+				config.NextProtos = []string{"h2", "http/1.1"}
+			})
+			proxy, proxyHellos := serveHTTPSProxy(t, newTestCert(t, "proxy.example", proxyCA))
+			_, port, _ := net.SplitHostPort(proxy.Listener.Addr().String())
+
+			opts := append([]Option{
+				WithLogger(logging.NewFakeLogger()),
+				WithUpstreamProxy(&url.URL{Scheme: "https", Host: net.JoinHostPort("proxy.example", port)}),
+				WithResolver(resolver.NewHostsResolver(map[string][]net.IP{"proxy.example": {net.IPv4(127, 0, 0, 1)}}, nil)),
+				WithServerName("origin.example"),
+				WithALPN("http/1.1"),
+			}, tt.opts...)
+			dialer, err := NewTLSDialerE(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 单次拨号的SNI和ALPN只作用于目标服务器，不影响与代理服务器的握手
+			ctx := ContextWithVerifyServerName(ContextWithServerName(context.Background(), "front.example"), "origin.example")
+			ctx = ContextWithALPN(ctx, "h2")
+			conn, err := dialer.DialTLS(ctx, "tcp", targetAddr)
+
+			proxyHello := <-proxyHellos
+			if proxyHello.ServerName != "proxy.example" {
+				t.Errorf("代理服务器收到的SNI = %q, want proxy.example", proxyHello.ServerName)
+			}
+			if !slices.Equal(proxyHello.ALPN, []string{"http/1.1"}) {
+				t.Errorf("代理服务器收到的ALPN = %v, want [http/1.1]", proxyHello.ALPN)
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("DialTLS() 代理服务器证书校验失败时应返回错误")
+				}
+				var unknownErr x509.UnknownAuthorityError
+				if !errors.As(err, &unknownErr) {
+					t.Errorf("DialTLS() error = %v, want x509.UnknownAuthorityError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DialTLS() error = %v", err)
+			}
+			defer conn.Close()
+
+			targetHello := <-targetHellos
+			if targetHello.ServerName != "front.example" {
+				t.Errorf("目标服务器收到的SNI = %q, want front.example", targetHello.ServerName)
+			}
+			if !slices.Equal(targetHello.ALPN, []string{"h2"}) {
+				t.Errorf("目标服务器收到的ALPN = %v, want [h2]", targetHello.ALPN)
+			}
+			if got := conn.NegotiatedProtocol(); got != "h2" {
+				t.Errorf("NegotiatedProtocol() = %q, want h2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// serverNameConfig 控制握手时发送的SNI以及证书校验使用的主机名
+type serverNameConfig struct {
+	serverName string // 覆盖SNI，为空时使用拨号地址中的主机名
+	omit       bool   // 不发送SNI
+	verifyName string // 证书校验使用的主机名，为空时与SNI相同
+}
+
+type serverNameKey struct{}
+
+func serverNameFromContext(ctx context.Context) serverNameConfig {
+	cfg, _ := ctx.Value(serverNameKey{}).(serverNameConfig)
+	return cfg
+}
+
+// ContextWithServerName 为单次拨号覆盖发送的SNI，优先于 WithServerName
+func ContextWithServerName(ctx context.Context, serverName string) context.Context {
+	cfg := serverNameFromContext(ctx)
+	cfg.serverName = serverName
+	cfg.omit = false
+	return context.WithValue(ctx, serverNameKey{}, cfg)
+}
+
+// ContextWithoutServerName 单次拨号不发送SNI，证书仍按拨号地址或校验主机名进行校验
+func ContextWithoutServerName(ctx context.Context) context.Context {
+	cfg := serverNameFromContext(ctx)
+	cfg.omit = true
+	return context.WithValue(ctx, serverNameKey{}, cfg)
+}
+
+// ContextWithVerifyServerName 为单次拨号指定证书校验使用的主机名，可与SNI不同 (如域前置)
+func ContextWithVerifyServerName(ctx context.Context, verifyName string) context.Context {
+	cfg := serverNameFromContext(ctx)
+	cfg.verifyName = verifyName
+	return context.WithValue(ctx, serverNameKey{}, cfg)
+}
+
+// resolveServerNames 按 单次拨号 > 拨号器配置 > 拨号地址 的优先级确定发送的SNI和证书校验主机名，
+// 不发送SNI时 sni 为空
+func (d *BaseTLSDialer) resolveServerNames(ctx context.Context, host string) (sni, verifyName string) {
+	perDial := serverNameFromContext(ctx)
+
+	sni = host
+	if d.opts.serverName != "" {
+		sni = d.opts.serverName
+	}
+	if perDial.serverName != "" {
+		sni = perDial.serverName
+	}
+
+	verifyName = sni
+	if d.opts.verifyServerName != "" {
+		verifyName = d.opts.verifyServerName
+	}
+	if perDial.verifyName != "" {
+		verifyName = perDial.verifyName
+	}
+
+	if perDial.omit || (d.opts.omitServerName && perDial.serverName == "") {
+		sni = ""
+	}
+	return sni, verifyName
+}
+
+// explicitServerName 是否显式指定了要发送的SNI
+func (d *BaseTLSDialer) explicitServerName(ctx context.Context) bool {
+	return d.opts.serverName != "" || serverNameFromContext(ctx).serverName != ""
+}
+
+// adjustSNIExtension 使 ClientHelloSpec 中的 SNIExtension 与实际发送的SNI保持一致:
+// 不发送SNI或SNI为IP地址时移除该扩展 (与浏览器行为一致)，显式指定SNI而规范中缺少该扩展时插入到GREASE之后
+func adjustSNIExtension(spec *utls.ClientHelloSpec, sni string, explicit bool) {
+	isSNI := func(ext utls.TLSExtension) bool {
+		_, ok := ext.(*utls.SNIExtension)
+		return ok
+	}
+
+	if sni == "" || net.ParseIP(sni) != nil {
+		spec.Extensions = slices.DeleteFunc(spec.Extensions, isSNI)
+		return
+	}
+	if !explicit || slices.ContainsFunc(spec.Extensions, isSNI) {
+		return
+	}
+
+	i := 0
+	for i < len(spec.Extensions) {
+		if _, ok := spec.Extensions[i].(*utls.UtlsGREASEExtension); !ok {
+			break
+		}
+		i++
+	}
+	spec.Extensions = slices.Insert(spec.Extensions, i, utls.TLSExtension(&utls.SNIExtension{}))
+}
+
+// verifyWithName 按指定主机名手动校验服务器证书链，用于SNI与校验主机名不一致或不发送SNI的场景，
+// 校验通过后以得到的证书链调用 next
+func (d *BaseTLSDialer) verifyWithName(verifyName string, next verifyPeerFunc) verifyPeerFunc {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("服务器未提供证书")
+		}
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("解析服务器证书失败: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		chains, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         d.opts.rootCAs,
+			DNSName:       verifyName,
+			Intermediates: intermediates,
+		})
+		if err != nil {
+			return fmt.Errorf("证书校验失败: %w", err)
+		}
+
+		if next != nil {
+			return next(rawCerts, chains)
+		}
+		return nil
+	}
+}
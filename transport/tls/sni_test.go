@@ -0,0 +1,249 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
+)
+
+// clientHello 测试服务器收到的ClientHello
+type clientHello struct {
+	ServerName string
+	ALPN       []string
+	Extensions []uint16
+}
+
+// serveHello 在本地启动使用 leaf 证书的TLS服务器，将每次握手收到的ClientHello发送到返回的通道，
+// configure 可以修改服务器配置，测试结束时关闭
+func serveHello(t *testing.T, leaf *testCert, configure func(*tls.Config)) (string, <-chan clientHello) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	hellos := make(chan clientHello, 4)
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.cert.Raw}, PrivateKey: leaf.key}},
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hellos <- clientHello{
+				ServerName: info.ServerName,
+				ALPN:       slices.Clone(info.SupportedProtos),
+				Extensions: slices.Clone(info.Extensions),
+			}
+			return nil, nil
+		},
+	}
+	if configure != nil {
+		configure(config)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				tlsConn := tls.Server(conn, config)
+				defer tlsConn.Close()
+				_ = tlsConn.Handshake()
+			}()
+		}
+	}()
+	return ln.Addr().String(), hellos
+}
+
+// chromeWithoutSNI Chrome 124 去掉 server_name 扩展
+func chromeWithoutSNI() *utls.ClientHelloSpec {
+	spec := fingerprint.GetChrome124ClientHelloSpec()
+	spec.Extensions = slices.DeleteFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+		_, ok := ext.(*utls.SNIExtension)
+		return ok
+	})
+	return spec
+}
+
+func hasSNIExtension(spec *utls.ClientHelloSpec) bool {
+	return slices.IndexFunc(spec.Extensions, func(ext utls.TLSExtension) bool {
+		_, ok := ext.(*utls.SNIExtension)
+		return ok
+	}) >= 0
+}
+
+func TestAdjustSNIExtension(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     func() *utls.ClientHelloSpec
+		sni      string
+		explicit bool
+		wantSNI  bool
+	}{
+		{name: "域名保留", spec: fingerprint.GetChrome124ClientHelloSpec, sni: "example.com", wantSNI: true},
+		{name: "IPv4地址移除", spec: fingerprint.GetChrome124ClientHelloSpec, sni: "192.0.2.1"},
+		{name: "IPv6地址移除", spec: fingerprint.GetChrome124ClientHelloSpec, sni: "2001:db8::1"},
+		{name: "显式指定IP地址同样移除", spec: fingerprint.GetChrome124ClientHelloSpec, sni: "192.0.2.1", explicit: true},
+		{name: "不发送SNI移除", spec: fingerprint.GetChrome124ClientHelloSpec, sni: ""},
+		{name: "未显式指定时不插入", spec: chromeWithoutSNI, sni: "example.com"},
+		{name: "显式指定时插入", spec: chromeWithoutSNI, sni: "example.com", explicit: true, wantSNI: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec()
+			n := len(spec.Extensions)
+			adjustSNIExtension(spec, tt.sni, tt.explicit)
+			if got := hasSNIExtension(spec); got != tt.wantSNI {
+				t.Errorf("包含SNI扩展 = %v, want %v", got, tt.wantSNI)
+			}
+			if had := hasSNIExtension(tt.spec()); had == tt.wantSNI && len(spec.Extensions) != n {
+				t.Errorf("扩展数量 %d -> %d，不应增删其他扩展", n, len(spec.Extensions))
+			}
+		})
+	}
+}
+
+func TestAdjustSNIExtensionPosition(t *testing.T) {
+	tests := []struct {
+		name       string
+		extensions []utls.TLSExtension
+		want       int
+	}{
+		{
+			name:       "插入到GREASE之后",
+			extensions: []utls.TLSExtension{&utls.UtlsGREASEExtension{}, &utls.ExtendedMasterSecretExtension{}, &utls.UtlsGREASEExtension{}},
+			want:       1,
+		},
+		{
+			name:       "没有GREASE时插入到开头",
+			extensions: []utls.TLSExtension{&utls.ExtendedMasterSecretExtension{}, &utls.UtlsGREASEExtension{}},
+			want:       0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &utls.ClientHelloSpec{Extensions: tt.extensions}
+			adjustSNIExtension(spec, "example.com", true)
+			if len(spec.Extensions) != len(tt.extensions)+1 {
+				t.Fatalf("扩展数量 = %d, want %d", len(spec.Extensions), len(tt.extensions)+1)
+			}
+			if _, ok := spec.Extensions[tt.want].(*utls.SNIExtension); !ok {
+				t.Errorf("第 %d 个扩展为 %T, want *utls.SNIExtension", tt.want, spec.Extensions[tt.want])
+			}
+		})
+	}
+}
+
+func TestDialServerName(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, "origin.example", ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		ctx     func(context.Context) context.Context
+		wantSNI string
+		wantErr bool // 证书与校验主机名不匹配
+	}{
+		{name: "IP地址不发送SNI"},
+		{name: "WithServerName", opts: []Option{WithServerName("origin.example")}, wantSNI: "origin.example"},
+		{
+			name: "指纹缺少SNI扩展时插入",
+			opts: []Option{WithSpecFactory(chromeWithoutSNI), WithServerName("origin.example")}, wantSNI: "origin.example",
+		},
+		{
+			name: "单次拨号覆盖SNI",
+			opts: []Option{WithServerName("other.example")},
+			ctx: func(ctx context.Context) context.Context {
+				return ContextWithServerName(ctx, "origin.example")
+			},
+			wantSNI: "origin.example",
+		},
+		{
+			name: "不发送SNI并按校验主机名校验",
+			opts: []Option{WithServerName("origin.example"), WithoutServerName()},
+		},
+		{
+			name: "SNI与校验主机名不同",
+			ctx: func(ctx context.Context) context.Context {
+				return ContextWithVerifyServerName(ContextWithServerName(ctx, "front.example"), "origin.example")
+			},
+			wantSNI: "front.example",
+		},
+		{
+			name: "按SNI校验失败",
+			ctx: func(ctx context.Context) context.Context {
+				return ContextWithServerName(ctx, "front.example")
+			},
+			wantSNI: "front.example", wantErr: true,
+		},
+		{
+			name: "按校验主机名校验失败",
+			ctx: func(ctx context.Context) context.Context {
+				return ContextWithVerifyServerName(ContextWithServerName(ctx, "origin.example"), "other.example")
+			},
+			wantSNI: "origin.example", wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, hellos := serveHello(t, leaf, nil)
+			opts := append([]Option{WithLogger(logging.NewFakeLogger()), WithRootCAs(roots)}, tt.opts...)
+			dialer, err := NewTLSDialerE(opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx(ctx)
+			}
+
+			conn, err := dialer.DialTLS(ctx, "tcp", addr)
+			if tt.wantErr {
+				var hostErr x509.HostnameError
+				if !errors.As(err, &hostErr) {
+					t.Errorf("DialTLS() error = %v, want x509.HostnameError", err)
+				}
+			} else if err != nil {
+				t.Fatalf("DialTLS() error = %v", err)
+			}
+			if conn != nil {
+				conn.Close()
+			}
+
+			hello := <-hellos
+			if hello.ServerName != tt.wantSNI {
+				t.Errorf("服务器收到的SNI = %q, want %q", hello.ServerName, tt.wantSNI)
+			}
+			if sent := slices.Contains(hello.Extensions, 0); sent != (tt.wantSNI != "") {
+				t.Errorf("ClientHello 包含 server_name 扩展 = %v", sent)
+			}
+		})
+	}
+}
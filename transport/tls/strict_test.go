@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2024 aberstone
+ *
+ * This library is free software; you can redistribute it and/or
+ * modify it under the terms of the GNU Lesser General Public
+ * License as published by the Free Software Foundation; either
+ * version 3 of the License, or (at your option) any later version.
+ *
+ * This library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU
+ * Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public
+ * License along with this library; if not, write to the Free Software
+ * Foundation, Inc., 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA
+ */
+package tls
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aberstone/fingertls/logging"
+	"github.com/aberstone/fingertls/transport/tls/fingerprint"
+	utls "github.com/refraction-networking/utls"
+)
+
+func TestDialStrictSpec(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// 服务端记录连接关闭前收到的字节数
+	received := make(chan int64, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- -1
+			return
+		}
+		defer conn.Close()
+		n, _ := io.Copy(io.Discard, conn)
+		received <- n
+	}()
+
+	// 重复的 extended_master_secret 扩展可以被 utls 发出，但会被服务器拒绝
+	spec := func() *utls.ClientHelloSpec {
+		spec := fingerprint.GetChrome124ClientHelloSpec()
+		spec.Extensions = append(spec.Extensions, &utls.ExtendedMasterSecretExtension{})
+		return spec
+	}
+	dialer, err := NewTLSDialerE(
+		WithLogger(logging.NewFakeLogger()),
+		WithSpecFactory(spec),
+		WithStrictSpec(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	conn, err := dialer.DialTLS(ctx, "tcp", ln.Addr().String())
+	if err == nil {
+		conn.Close()
+		t.Fatal("DialTLS() 严格模式下应拒绝有问题的指纹")
+	}
+	var verr *fingerprint.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("DialTLS() error = %v, want *fingerprint.ValidationError", err)
+	}
+	if len(verr.Warnings) != 1 || verr.Warnings[0].Code != fingerprint.WarnDuplicateExtension {
+		t.Errorf("Warnings = %v, want [%s]", verr.Warnings, fingerprint.WarnDuplicateExtension)
+	}
+
+	select {
+	case n := <-received:
+		if n != 0 {
+			t.Errorf("服务端收到 %d 字节，校验失败时不应发送任何数据", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("校验失败后连接未被关闭")
+	}
+}